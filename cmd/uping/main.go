@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"math/rand"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,18 +17,28 @@ import (
 var usage = `Uberping.
 
 Usage:
+  uping sweep [options] <networks>...
+  uping reflect [options] [<listen>]
   uping [options] [<hosts>...]
   uping -h | --help
   uping --version
 
 Options:
-  --mode ping|netcat       Set type of probe operation: ping with unprivileged udp, icmp or try to connect using tcp port (default: icmp)
+  --mode <mode>            Set type of probe operation: ping with unprivileged udp, icmp, netcat trying to connect using tcp port,
+                           ssh performing SSH handshake verifying host key, snmp sending SNMP GET requests,
+                           ntp measuring clock offset of NTP server, sql connecting to database and running test query,
+                           grpc calling standard gRPC health check, dhcp sending DHCPDISCOVER and waiting for offer
+                           radius authenticating test user, arp resolving MAC address of directly connected host,
+                           timestamp sending ICMP Timestamp Requests estimating one-way delays
+                           ratelimit comparing loss of ping burst and spaced train detecting ICMP rate limiting
+                           or twamp sending TWAMP-Light test packets to reflector (default: ping)
   -p udp|icmp|tcp          Set a protocol for selected above mode, for ping: udp|icmp, for netcat: tcp (default: icmp for ping and tcp for netcat)
   -d <tests-interval>      Interval between tests, if provided uping will perform tests indefinitely, e.g. every -I 1m, -I 1m30s, -I 1h30m10s
   -C <config-file>         Use configuration file, eg. API endpoints, secrets, etc...
   -s                       Be silent and don't print output to stdout, only errors to stderr
   -g                       Print grouped results
-  -P <default-port>        In case of netcat mode use <default-port> for hosts without explicitly specified port, e.g. -p 8080
+  -P <default-ports>       Use <default-ports> for hosts without explicitly specified ports, e.g. -P 8080, lists of ports are supported
+                           only by netcat mode, e.g. -P 22,80,443, -P 8000-8100
   -f                       Use fallback mode, uping will try to use next ping mode if selected by -p failed
   -c <count>               Number of pings to perform (default: 4)
   -i <ping-interval>       Interval between pings, e.g. -i 1s, -i 100ms (default: 1s)
@@ -34,25 +47,82 @@ Options:
   --source-db              Load hosts using database configured by -C <config-file>
   --source-api             Load hosts using external API configured by -C <config-file>
   --source-file <file-in>  Load hosts from file <file-in>
+  --lenient                Skip and report hosts which can't be parsed instead of exiting
   --out-db                 Save tests results database configured by -C <config-file>
   --out-api                Save tests results using external API configured by -C <config-file>
   --out-file <file-out>    Save tests results to file <file-out>
+  --pps <pps>              Limit packets per second sent by all workers together, e.g. --pps 500 (default: unlimited),
+                           bursts of ratelimit mode are sent unlimited on purpose
+  --jitter <jitter>        Start hosts of each tests round at random offsets spread over <jitter>, e.g. --jitter 10s,
+                           at most half of <tests-interval>
+  --confirm <attempts>     Re-probe failed hosts up to <attempts> times before reporting them down (default: 0)
+  --rate <rate>            In sweep mode probe at most <rate> addresses per second (default: 100)
+  --ports <ports>          In sweep mode try also to connect to tcp <ports>, e.g. --ports 22,80,443, --ports 8000-8100
 `
 
 const version = "0.3.6"
 
-func loadHosts(hostsLoaders *[]schema.HostsLoader, hosts *schema.Hosts) {
+func loadHosts(hostsSources *[]*schema.HostsSource, hosts *schema.Hosts) {
 	hosts.Reset()
-	for _, hostsLoader := range *hostsLoaders {
-		if err := hosts.Add(hostsLoader); err != nil {
+	for _, hostsSource := range *hostsSources {
+		if err := hosts.Add(hostsSource.Load); err != nil {
 			log.Fatal(err)
 		}
 	}
+
+	for _, invalid := range hosts.Invalid() {
+		log.Printf("Skipping invalid host %s: %v\n", invalid.Host.IP, invalid)
+	}
+
+	if len(*hostsSources) > 1 {
+		log.Printf("Collapsed %d duplicated hosts\n", hosts.Duplicates())
+	}
+}
+
+func pushJobs(push func(schema.Host), hosts *schema.Hosts, rate int, jitter time.Duration) {
+	var throttle <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	// hosts are started in random order at random offsets spread over jitter
+	list := hosts.Get()
+	var offsets []time.Duration
+	if jitter > 0 {
+		list = append([]schema.Host(nil), list...)
+		rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+		for range list {
+			offsets = append(offsets, time.Duration(rand.Int63n(int64(jitter))))
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	}
+
+	start := time.Now()
+	for i, host := range list {
+		if offsets != nil {
+			time.Sleep(time.Until(start.Add(offsets[i])))
+		}
+		if throttle != nil {
+			<-throttle
+		}
+		push(host)
+	}
 }
 
-func pushJobs(jobs chan schema.Host, hosts *schema.Hosts) {
-	for _, host := range hosts.Get() {
-		jobs <- host
+func pushUnresolved(appConfig schema.GeneralConfig, hosts *schema.Hosts) {
+	if !appConfig.Sources.ReportUnresolved {
+		return
+	}
+
+	for _, invalid := range hosts.Invalid() {
+		appConfig.Results <- schema.ProbeResult{
+			Host:   invalid.Host,
+			Status: schema.StatusUnresolved,
+			Output: []string{fmt.Sprintf("Host %s unresolved, %v!\n", invalid.Host.IP, invalid)},
+			Loss:   100,
+		}
 	}
 }
 
@@ -62,18 +132,37 @@ func main() {
 	arguments, _ := docopt.ParseArgs(usage, os.Args[1:], version)
 	//fmt.Println(arguments)
 
+	if arguments["reflect"].(bool) {
+		reflect(arguments)
+		return
+	}
+
 	appConfig := schema.GeneralConfig{}
-	hostsLoaders, resultsSavers, cleaners := configParser(arguments, &appConfig)
+	hostsSources, resultsSavers, cleaners := configParser(arguments, &appConfig)
 
 	// Load list of hosts
 	Hosts.Init(appConfig.Probe.DefaultPort)
-	loadHosts(&hostsLoaders, &Hosts)
+	Hosts.SetDefaultPorts(appConfig.Probe.DefaultPorts)
+	Hosts.SetLenient(appConfig.Sources.Lenient)
+	for _, hostsSource := range hostsSources {
+		hostsSource.Lenient = appConfig.Sources.Lenient
+	}
+	Hosts.SetDeduplication(appConfig.Probe.Mode, appConfig.Sources.MergePriority)
+	Hosts.SetResolver(appConfig.Resolver.Client)
+	loadHosts(&hostsSources, &Hosts)
 	if len(Hosts.Get()) == 0 {
 		log.Fatalln("No hosts to test.")
 	}
 
+	// Only sweep is throttled by number of addresses per second
+	var rate int
+	if arguments["sweep"].(bool) {
+		rate = appConfig.Sweep.Rate
+	}
+
 	// Create workers pool
 	jobs := make(chan schema.Host, appConfig.Workers)
+	confirmer := worker.NewConfirmer(appConfig.Confirm, jobs)
 	appConfig.Results = make(chan schema.ProbeResult, len(Hosts.Get()))
 
 	var wgWorker sync.WaitGroup
@@ -84,10 +173,11 @@ func main() {
 
 	for i := 0; i < appConfig.Workers; i++ {
 		wgWorker.Add(1)
-		go appConfig.Probe.Worker(i, appConfig, jobs, &wgWorker)
+		go confirmer.Worker(appConfig.Probe.Worker)(i, appConfig, jobs, &wgWorker)
 	}
 
-	pushJobs(jobs, &Hosts)
+	pushUnresolved(appConfig, &Hosts)
+	pushJobs(confirmer.Push, &Hosts, rate, appConfig.Pacing.Jitter.Duration)
 
 	if appConfig.TestsInterval.Seconds() > 0.0 {
 		ticker := time.NewTicker(appConfig.TestsInterval.Duration)
@@ -95,12 +185,14 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				loadHosts(&hostsLoaders, &Hosts)
-				pushJobs(jobs, &Hosts)
+				loadHosts(&hostsSources, &Hosts)
+				pushUnresolved(appConfig, &Hosts)
+				pushJobs(confirmer.Push, &Hosts, rate, appConfig.Pacing.Jitter.Duration)
 			}
 		}
 	}
 
+	confirmer.Wait()
 	close(jobs)
 	wgWorker.Wait()
 
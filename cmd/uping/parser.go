@@ -1,18 +1,32 @@
 package main
 
 import (
+	"database/sql"
 	"log"
+	"net"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/migotom/uberping/internal/driver"
+	"github.com/migotom/uberping/internal/enricher"
+	"github.com/migotom/uberping/internal/pacer"
+	"github.com/migotom/uberping/internal/resolver"
 	"github.com/migotom/uberping/internal/schema"
 	"github.com/migotom/uberping/internal/schema/config"
 	"github.com/migotom/uberping/internal/worker"
+	"github.com/migotom/uberping/internal/worker/arpprobe"
+	"github.com/migotom/uberping/internal/worker/dhcpprobe"
+	goping "github.com/migotom/uberping/internal/worker/ping"
+	"github.com/migotom/uberping/internal/worker/snmpprobe"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
-func configParser(arguments map[string]interface{}, appConfig *schema.GeneralConfig) ([]schema.HostsLoader, []worker.ResultsSaver, []schema.HostsCleaner) {
-	var hostsLoaders []schema.HostsLoader
+func configParser(arguments map[string]interface{}, appConfig *schema.GeneralConfig) ([]*schema.HostsSource, []worker.ResultsSaver, []schema.HostsCleaner) {
+	var hostsSources []*schema.HostsSource
 	var resultsSavers []worker.ResultsSaver
 	var cleaners []schema.HostsCleaner
 
@@ -28,25 +42,177 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 	// Override config by args
 	appConfig.Verbose = !arguments["-s"].(bool)
 	appConfig.Grouped = arguments["-g"].(bool)
+	if lenient := arguments["--lenient"].(bool); lenient {
+		appConfig.Sources.Lenient = true
+	}
 
 	if mode, ok := arguments["--mode"].(string); ok {
 		appConfig.Probe.Mode = mode
 	}
+
+	// sweep uses ping to discover hosts regardless of configured mode
+	sweep := arguments["sweep"].(bool)
+	if sweep {
+		appConfig.Probe.Mode = "ping"
+	}
+
+	// single default port is used by every mode, lists of ports are probed only by netcat
+	if defaultPorts, ok := arguments["-P"].(string); ok {
+		ports, err := schema.ParsePorts(defaultPorts)
+		if err != nil {
+			log.Fatalf("Invalid default ports %s: %v\n", defaultPorts, err)
+		}
+		if len(ports) > 1 && appConfig.Probe.Mode != "netcat" {
+			log.Fatalf("List of default ports %s is supported only by netcat mode.\n", defaultPorts)
+		}
+		appConfig.Probe.DefaultPort, _ = strconv.Atoi(ports[0])
+		appConfig.Probe.DefaultPorts = defaultPorts
+	}
+	if appConfig.Probe.Mode != "netcat" {
+		appConfig.Probe.DefaultPorts = ""
+	}
 	switch appConfig.Probe.Mode {
-	case "ping":
+	case "ping", "timestamp":
 		appConfig.Probe.Worker = worker.Pinger
+		if size := appConfig.Probe.Ping.Size; size != 0 && size < goping.HeaderLength {
+			log.Fatalf("Ping payload size must be at least %d bytes.\n", goping.HeaderLength)
+		}
+		if appConfig.Probe.Ping.Size > goping.MaxLength {
+			log.Fatalf("Ping payload size must be at most %d bytes.\n", goping.MaxLength)
+		}
+		if appConfig.Probe.Ping.Pattern != "" {
+			pattern, err := goping.ParsePattern(appConfig.Probe.Ping.Pattern)
+			if err != nil {
+				log.Fatalf("Invalid ping payload pattern: %v\n", err)
+			}
+			appConfig.Probe.Ping.Payload = pattern
+		}
+	case "ratelimit":
+		appConfig.Probe.Worker = worker.RateLimit
+		if appConfig.Probe.RateLimit.Burst == 0 {
+			appConfig.Probe.RateLimit.Burst = 50
+		}
+		if appConfig.Probe.RateLimit.BurstInterval.Duration == 0 {
+			appConfig.Probe.RateLimit.BurstInterval.Duration = time.Millisecond
+		}
+		if appConfig.Probe.RateLimit.Threshold == 0 {
+			appConfig.Probe.RateLimit.Threshold = 30
+		}
 	case "netcat":
 		appConfig.Probe.Worker = worker.Netcat
+	case "ssh":
+		appConfig.Probe.Worker = worker.SSH
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 22
+		}
+		if knownHosts := appConfig.Probe.SSH.KnownHosts; knownHosts != "" {
+			callback, err := knownhosts.New(knownHosts)
+			if err != nil {
+				log.Fatalf("Can't load known hosts file: %v\n", err)
+			}
+			appConfig.Probe.SSH.HostKeyCallback = callback
+		}
+	case "snmp":
+		appConfig.Probe.Worker = worker.SNMP
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 161
+		}
+		switch appConfig.Probe.SNMP.Version {
+		case "1", "2c", "":
+			// do nothing
+		case "3":
+			log.Fatalln("Unsupported yet SNMP version.")
+		default:
+			log.Fatalln("Unsupported SNMP version.")
+		}
+		appConfig.Probe.SNMP.Uptimes = snmpprobe.NewUptimes()
+	case "ntp":
+		appConfig.Probe.Worker = worker.NTP
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 123
+		}
+	case "sql":
+		appConfig.Probe.Worker = worker.SQL
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 5432
+		}
+		if appConfig.Probe.SQL.Driver == "" {
+			appConfig.Probe.SQL.Driver = "postgres"
+		}
+		if !knownSQLDriver(appConfig.Probe.SQL.Driver) {
+			log.Fatalf("Unknown SQL driver %s, available: %s\n", appConfig.Probe.SQL.Driver, strings.Join(sql.Drivers(), ", "))
+		}
+		if appConfig.Probe.SQL.DSN == "" {
+			log.Fatalln("Missing DSN template for sql mode.")
+		}
+		dsn, err := template.New("dsn").Option("missingkey=zero").Parse(appConfig.Probe.SQL.DSN)
+		if err != nil {
+			log.Fatalf("Invalid DSN template: %v\n", err)
+		}
+		appConfig.Probe.SQL.Template = dsn
+	case "grpc":
+		appConfig.Probe.Worker = worker.GRPC
+	case "dhcp":
+		appConfig.Probe.Worker = worker.DHCP
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 67
+		}
+		if mac := appConfig.Probe.DHCP.ClientMAC; mac != "" {
+			hw, err := net.ParseMAC(mac)
+			if err != nil {
+				log.Fatalf("Invalid DHCP client MAC: %v\n", err)
+			}
+			if len(hw) > dhcpprobe.MaxClientMACLength {
+				log.Fatalf("Invalid DHCP client MAC: %s is longer than %d bytes\n", mac, dhcpprobe.MaxClientMACLength)
+			}
+			appConfig.Probe.DHCP.HardwareAddr = hw
+		}
+		if giaddr := appConfig.Probe.DHCP.Giaddr; giaddr != "" {
+			if appConfig.Probe.DHCP.RelayAddr = net.ParseIP(giaddr).To4(); appConfig.Probe.DHCP.RelayAddr == nil {
+				log.Fatalln("Invalid DHCP giaddr.")
+			}
+		}
+	case "radius":
+		appConfig.Probe.Worker = worker.RADIUS
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 1812
+		}
+		if appConfig.Probe.RADIUS.Password == "" {
+			appConfig.Probe.RADIUS.Password = os.Getenv("UPING_RADIUS_PASSWORD")
+		}
+		if appConfig.Probe.RADIUS.Secret == "" {
+			appConfig.Probe.RADIUS.Secret = os.Getenv("UPING_RADIUS_SECRET")
+		}
+		if appConfig.Probe.RADIUS.User == "" || appConfig.Probe.RADIUS.Secret == "" {
+			log.Fatalln("Missing RADIUS test user or shared secret.")
+		}
+	case "twamp":
+		appConfig.Probe.Worker = worker.TWAMP
+		if appConfig.Probe.DefaultPort == 0 {
+			appConfig.Probe.DefaultPort = 862
+		}
+	case "arp":
+		appConfig.Probe.Worker = worker.ARP
+		if appConfig.Probe.ARP.OUIFile != "" {
+			vendors, err := arpprobe.LoadVendors(appConfig.Probe.ARP.OUIFile)
+			if err != nil {
+				log.Fatalf("Can't load OUI database: %v\n", err)
+			}
+			appConfig.Probe.ARP.Vendors = vendors
+		}
 	case "":
 		appConfig.Probe.Worker = worker.Pinger
 	default:
 		log.Fatalln("Unsupported mode.")
 	}
+	if sweep {
+		appConfig.Probe.Worker = worker.Sweeper
+	}
 
 	if proto, ok := arguments["-p"].(string); ok {
 		appConfig.Probe.Protocol = proto
 	}
-	switch proto := appConfig.Probe.Protocol; appConfig.Probe.Mode == "ping" {
+	switch proto := appConfig.Probe.Protocol; appConfig.Probe.Mode == "ping" || appConfig.Probe.Mode == "ratelimit" {
 	case proto == "udp":
 		appConfig.Probe.Privileged = false
 	case proto == "icmp":
@@ -56,6 +222,15 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 	default:
 		log.Fatalln("Unsupported protocol for ping mode.")
 	}
+	if appConfig.Probe.Mode == "timestamp" {
+		// timestamp requests can be sent only using privileged icmp
+		switch appConfig.Probe.Protocol {
+		case "icmp", "":
+			appConfig.Probe.Privileged = true
+		default:
+			log.Fatalln("Unsupported protocol for timestamp mode, only icmp is allowed.")
+		}
+	}
 	switch proto := appConfig.Probe.Protocol; appConfig.Probe.Mode == "netcat" {
 	case proto == "tcp":
 		// do nothing yet
@@ -67,10 +242,28 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 		log.Fatalln("Unsupported protocol for netcat mode.")
 	}
 
-	if defaultPort, ok := arguments["-P"].(string); ok {
-		if defaultPort, err := strconv.ParseInt(defaultPort, 10, 64); err == nil {
-			appConfig.Probe.DefaultPort = int(defaultPort)
+	for name, script := range appConfig.Scripts {
+		for i, step := range script.Steps {
+			if step.Expect == "" {
+				continue
+			}
+			pattern, err := regexp.Compile("(?m)" + step.Expect)
+			if err != nil {
+				log.Fatalf("Invalid expect of script %s step %d: %v\n", name, i+1, err)
+			}
+			script.Steps[i].Pattern = pattern
+		}
+	}
+
+	appConfig.Resolver.Client = resolver.NewResolver(appConfig.Resolver)
+
+	if appConfig.Enrichment.ReverseDNS || len(appConfig.Enrichment.Databases) > 0 {
+		e, err := enricher.NewEnricher(appConfig.Enrichment)
+		if err != nil {
+			log.Fatal(err)
 		}
+		appConfig.Enrichment.Client = e
+		cleaners = append(cleaners, e.Close)
 	}
 
 	if appConfig.Verbose {
@@ -96,6 +289,9 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 
 	if appConfig.Probe.Count == 0 {
 		appConfig.Probe.Count = 4
+		if sweep {
+			appConfig.Probe.Count = 1
+		}
 	}
 	if count, ok := arguments["-c"].(string); ok {
 		if count, err := strconv.ParseInt(count, 10, 64); err == nil {
@@ -114,38 +310,139 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 
 	if appConfig.Workers == 0 {
 		appConfig.Workers = 4
+		if sweep {
+			appConfig.Workers = 64
+		}
 	}
 	if workers, ok := arguments["-w"].(string); ok {
 		if workers, err := strconv.ParseInt(workers, 10, 64); err == nil {
 			appConfig.Workers = int(workers)
 		}
 	}
+	if attempts, ok := arguments["--confirm"].(string); ok {
+		if attempts, err := strconv.ParseInt(attempts, 10, 64); err == nil {
+			appConfig.Confirm.Attempts = int(attempts)
+		}
+	}
+	if appConfig.Confirm.Attempts > 0 {
+		if appConfig.Confirm.Delay.Duration == 0 {
+			appConfig.Confirm.Delay.Duration = 2 * time.Second
+		}
+		if appConfig.Confirm.Count > 0 && appConfig.Confirm.Timeout.Duration == 0 {
+			appConfig.Confirm.Timeout.Duration = time.Duration(appConfig.Confirm.Count) * time.Second
+		}
+		switch appConfig.Confirm.Protocol {
+		case "":
+			// do nothing
+		case "icmp", "udp":
+			if appConfig.Probe.Mode != "ping" && appConfig.Probe.Mode != "ratelimit" {
+				log.Fatalln("Confirmation protocol is supported only by ping and ratelimit modes.")
+			}
+		default:
+			log.Fatalln("Unsupported confirmation protocol, only icmp and udp are allowed.")
+		}
+	}
+
+	if pps, ok := arguments["--pps"].(string); ok {
+		if pps, err := strconv.ParseInt(pps, 10, 64); err == nil {
+			appConfig.Pacing.Rate = int(pps)
+		}
+	}
+	if jitter, ok := arguments["--jitter"].(string); ok {
+		if jitter, err := time.ParseDuration(jitter); err == nil {
+			appConfig.Pacing.Jitter.Duration = jitter
+		}
+	}
+	// jitter delays pushing jobs of tests round, so it has to leave time for probing before next round
+	if interval := appConfig.TestsInterval.Duration; interval > 0 && appConfig.Pacing.Jitter.Duration > interval/2 {
+		log.Printf("Jitter %v capped to half of tests interval %v\n", appConfig.Pacing.Jitter.Duration, interval/2)
+		appConfig.Pacing.Jitter.Duration = interval / 2
+	}
+	if appConfig.Pacing.Rate > 0 || appConfig.Pacing.SubnetRate > 0 {
+		appConfig.Pacing.Client = pacer.NewPacer(appConfig.Pacing)
+	}
+
+	// offers are received on one well-known port, so DHCP servers are probed one by one
+	if appConfig.Probe.Mode == "dhcp" {
+		appConfig.Workers = 1
+	}
+
+	if sweep {
+		if appConfig.Sweep.Rate == 0 {
+			appConfig.Sweep.Rate = 100
+		}
+		if rate, ok := arguments["--rate"].(string); ok {
+			if rate, err := strconv.ParseInt(rate, 10, 64); err == nil {
+				appConfig.Sweep.Rate = int(rate)
+			}
+		}
+
+		if ports, ok := arguments["--ports"].(string); ok {
+			list, err := schema.ParsePorts(ports)
+			if err != nil {
+				log.Fatalln("Invalid sweep ports.")
+			}
+			appConfig.Sweep.Ports = nil
+			for _, port := range list {
+				port, _ := strconv.Atoi(port)
+				appConfig.Sweep.Ports = append(appConfig.Sweep.Ports, port)
+			}
+		}
+
+		networks := arguments["<networks>"].([]string)
+		hostsSources = append(hostsSources, schema.NewHostsSource("sweep", func(parser schema.HostParser) ([]schema.Host, error) {
+			return driver.SweepLoadHosts(parser, networks)
+		}, schema.SourceConfig{}))
+
+		if api := arguments["--out-api"].(bool); api {
+			resultsSavers = append(resultsSavers, func(result schema.ProbeResult) error {
+				return driver.APISaveSweepResult(result, &appConfig.API)
+			})
+		}
+
+		if file, ok := arguments["--out-file"].(string); ok {
+			resultsSavers = append(resultsSavers, func(result schema.ProbeResult) error {
+				return driver.FileSaveSweepResult(result, file)
+			})
+		}
+
+		if db := arguments["--out-db"].(bool); db {
+			resultsSavers = append(resultsSavers, func(result schema.ProbeResult) error {
+				return driver.DBSqlSaveSweepResult(result, &appConfig.DB)
+			})
+			cleaners = append(cleaners, func() {
+				driver.DBCleaner(&appConfig.DB)
+			})
+		}
+
+		return hostsSources, resultsSavers, cleaners
+	}
 
 	if hosts, ok := arguments["<hosts>"].([]string); ok {
-		hostsLoaders = append(hostsLoaders, func(parser schema.HostParser) ([]schema.Host, error) {
+		hostsSources = append(hostsSources, schema.NewHostsSource("argv", func(parser schema.HostParser) ([]schema.Host, error) {
 			return driver.ArgvLoadHosts(parser, hosts)
-		})
+		}, schema.SourceConfig{}))
 	}
 
 	if file, ok := arguments["--source-file"].(string); ok {
-		hostsLoaders = append(hostsLoaders, func(parser schema.HostParser) ([]schema.Host, error) {
+		hostsSources = append(hostsSources, schema.NewHostsSource("file", func(parser schema.HostParser) ([]schema.Host, error) {
 			return driver.FileLoadHosts(parser, file)
-		})
+		}, appConfig.Sources.File))
 	}
 
 	if db := arguments["--source-db"].(bool); db {
-		hostsLoaders = append(hostsLoaders, func(parser schema.HostParser) ([]schema.Host, error) {
+		hostsSources = append(hostsSources, schema.NewHostsSource("db", func(parser schema.HostParser) ([]schema.Host, error) {
 			return driver.DBSqlLoadHosts(parser, &appConfig.DB)
-		})
+		}, appConfig.Sources.DB))
 		cleaners = append(cleaners, func() {
 			driver.DBCleaner(&appConfig.DB)
 		})
 	}
 
 	if api := arguments["--source-api"].(bool); api {
-		hostsLoaders = append(hostsLoaders, func(parser schema.HostParser) ([]schema.Host, error) {
+		hostsSources = append(hostsSources, schema.NewHostsSource("api", func(parser schema.HostParser) ([]schema.Host, error) {
 			return driver.APILoadHosts(parser, &appConfig.API)
-		})
+		}, appConfig.Sources.API))
 	}
 
 	if api := arguments["--out-api"].(bool); api {
@@ -169,5 +466,15 @@ func configParser(arguments map[string]interface{}, appConfig *schema.GeneralCon
 		})
 	}
 
-	return hostsLoaders, resultsSavers, cleaners
+	return hostsSources, resultsSavers, cleaners
+}
+
+// knownSQLDriver tells if driver is registered in database/sql.
+func knownSQLDriver(driver string) bool {
+	for _, name := range sql.Drivers() {
+		if name == driver {
+			return true
+		}
+	}
+	return false
 }
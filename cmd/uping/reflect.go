@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"net"
+
+	"github.com/migotom/uberping/internal/worker/twampprobe"
+)
+
+// reflect runs TWAMP-Light reflector answering test packets until application is terminated.
+func reflect(arguments map[string]interface{}) {
+	address := ":862"
+	if listen, ok := arguments["<listen>"].(string); ok {
+		address = listen
+	}
+
+	reflector, err := twampprobe.NewReflector(address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer reflector.Close()
+
+	if !arguments["-s"].(bool) {
+		reflector.OnSession = func(addr net.Addr) {
+			log.Printf("New TWAMP-Light session from %s\n", addr)
+		}
+		log.Printf("Reflecting TWAMP-Light test packets on %s\n", reflector.Addr())
+	}
+	if err := reflector.Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -22,8 +22,26 @@ type authorizeResponse struct {
 }
 
 type updateDeviceRequest struct {
-	Loss    int     `json:"loss"`
-	AvgTime float64 `json:"average_time"`
+	Loss            int                      `json:"loss"`
+	Corruption      int                      `json:"corruption,omitempty"`
+	AvgTime         float64                  `json:"average_time"`
+	Status          string                   `json:"status,omitempty"`
+	TimestampSource string                   `json:"timestamp_source,omitempty"`
+	Confirmations   int                      `json:"confirmations,omitempty"`
+	Services        []schema.ServiceResult   `json:"services,omitempty"`
+	ICMPErrors      []schema.ICMPErrorResult `json:"icmp_errors,omitempty"`
+	SSH             *schema.SSHResult        `json:"ssh,omitempty"`
+	SNMP            *schema.SNMPResult       `json:"snmp,omitempty"`
+	NTP             *schema.NTPResult        `json:"ntp,omitempty"`
+	SQL             *schema.SQLResult        `json:"sql,omitempty"`
+	GRPC            *schema.GRPCResult       `json:"grpc,omitempty"`
+	DHCP            *schema.DHCPResult       `json:"dhcp,omitempty"`
+	RADIUS          *schema.RADIUSResult     `json:"radius,omitempty"`
+	ARP             *schema.ARPResult        `json:"arp,omitempty"`
+	Timestamp       *schema.TimestampResult  `json:"timestamp,omitempty"`
+	TWAMP           *schema.TWAMPResult      `json:"twamp,omitempty"`
+	RateLimit       *schema.RateLimitResult  `json:"rate_limit,omitempty"`
+	schema.Annotations
 }
 
 type apiClient struct {
@@ -133,16 +151,23 @@ func APILoadHosts(hostParser schema.HostParser, apiConfig *schema.APIConfig) ([]
 		return nil, err
 	}
 
-	for i, device := range apiDevices {
+	var hosts []schema.Host
+	var invalid schema.HostErrors
+	for _, device := range apiDevices {
 		ip, port, err := hostParser(device.IP)
 		if err != nil {
-			return nil, err
+			invalid = append(invalid, schema.HostError{Host: device, Err: err})
+			continue
 		}
-		apiDevices[i].IP = ip
-		apiDevices[i].Port = port
+		device.IP = ip
+		device.Port = port
+		hosts = append(hosts, device)
 	}
 
-	return apiDevices, nil
+	if invalid != nil {
+		return hosts, invalid
+	}
+	return hosts, nil
 }
 
 // APISavePingResult save probe results using external API
@@ -154,7 +179,28 @@ func APISavePingResult(result schema.ProbeResult, apiConfig *schema.APIConfig) e
 		return nil
 	}
 
-	apiDevResult := updateDeviceRequest{Loss: int(result.Loss), AvgTime: result.AvgTime}
+	apiDevResult := updateDeviceRequest{
+		Loss:            int(result.Loss),
+		Corruption:      int(result.Corruption),
+		AvgTime:         result.AvgTime,
+		Status:          result.Status,
+		TimestampSource: result.TimestampSource,
+		Confirmations:   result.Confirmations,
+		Services:        result.Services,
+		ICMPErrors:      result.ICMPErrors,
+		SSH:             result.SSH,
+		SNMP:            result.SNMP,
+		NTP:             result.NTP,
+		SQL:             result.SQL,
+		GRPC:            result.GRPC,
+		DHCP:            result.DHCP,
+		RADIUS:          result.RADIUS,
+		ARP:             result.ARP,
+		Timestamp:       result.Timestamp,
+		TWAMP:           result.TWAMP,
+		RateLimit:       result.RateLimit,
+		Annotations:     result.Annotations,
+	}
 
 	apiDevResultJSON, err := json.Marshal(apiDevResult)
 	if err != nil {
@@ -6,15 +6,19 @@ import (
 
 // ArgvLoadHosts loads lists of hosts using standard argument list.
 func ArgvLoadHosts(hostParser schema.HostParser, data []string) ([]schema.Host, error) {
-	hosts := make([]schema.Host, len(data))
-	for i, host := range data {
+	var hosts []schema.Host
+	var invalid schema.HostErrors
+	for _, host := range data {
 		ip, port, err := hostParser(host)
 		if err != nil {
-			return nil, err
+			invalid = append(invalid, schema.HostError{Host: schema.Host{IP: host}, Err: err})
+			continue
 		}
 
-		hosts[i].IP = ip
-		hosts[i].Port = port
+		hosts = append(hosts, schema.Host{IP: ip, Port: port})
+	}
+	if invalid != nil {
+		return hosts, invalid
 	}
 	return hosts, nil
 }
@@ -3,6 +3,8 @@ package driver
 import (
 	"errors"
 	"testing"
+
+	"github.com/migotom/uberping/internal/schema"
 )
 
 func trueParser(s string) (string, string, error) {
@@ -36,4 +38,9 @@ func TestLoadHostsInvalidData(t *testing.T) {
 	if err == nil {
 		t.Error(`argvLoadHosts() doesn't return error`)
 	}
+
+	invalid, ok := err.(schema.HostErrors)
+	if !ok || len(invalid) != 1 || invalid[0].Host.IP != data[0] {
+		t.Errorf(`argvLoadHosts() doesn't return invalid hosts, got: %v`, err)
+	}
 }
@@ -4,10 +4,29 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/migotom/uberping/internal/schema"
 )
 
+// parseFileLine splits hosts file line into host address and optional labels, e.g. "10.0.0.1:22 site=waw role=core".
+func parseFileLine(line string) (string, map[string]string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return strings.TrimSpace(line), nil, nil
+	}
+
+	labels := make(map[string]string)
+	for _, field := range fields[1:] {
+		label := strings.SplitN(field, "=", 2)
+		if len(label) != 2 || label[0] == "" {
+			return "", nil, fmt.Errorf("invalid label: %s", field)
+		}
+		labels[label[0]] = label[1]
+	}
+	return fields[0], labels, nil
+}
+
 // FileLoadHosts loads list of hosts from file
 func FileLoadHosts(hostParser schema.HostParser, filename string) ([]schema.Host, error) {
 	file, err := os.Open(filename)
@@ -17,18 +36,29 @@ func FileLoadHosts(hostParser schema.HostParser, filename string) ([]schema.Host
 	defer file.Close()
 
 	var hosts []schema.Host
+	var invalid schema.HostErrors
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		ip, port, err := hostParser(scanner.Text())
+		address, labels, err := parseFileLine(scanner.Text())
 		if err != nil {
-			return nil, err
+			invalid = append(invalid, schema.HostError{Host: schema.Host{IP: scanner.Text()}, Err: err})
+			continue
 		}
-		hosts = append(hosts, schema.Host{IP: ip, Port: port})
+
+		ip, port, err := hostParser(address)
+		if err != nil {
+			invalid = append(invalid, schema.HostError{Host: schema.Host{IP: address, Labels: labels}, Err: err})
+			continue
+		}
+		hosts = append(hosts, schema.Host{IP: ip, Port: port, Labels: labels})
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
+	if invalid != nil {
+		return hosts, invalid
+	}
 	return hosts, nil
 }
 
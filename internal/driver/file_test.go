@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/migotom/uberping/internal/schema"
@@ -31,7 +32,7 @@ func TestValidFileLoadHosts(t *testing.T) {
 		schema.Host{IP: "192.168.1.1", ID: 0},
 		schema.Host{IP: "192.168.88.1/24", ID: 0}}
 	for i := range testHosts {
-		if hosts == nil || testHosts[i] != hosts[i] {
+		if hosts == nil || !reflect.DeepEqual(testHosts[i], hosts[i]) {
 			t.Errorf("fileLoadHosts doesn't return valid host on id %d", i)
 		}
 	}
@@ -46,3 +47,17 @@ func TestValidFileFalseParserLoadHosts(t *testing.T) {
 		t.Error("fileLoadHosts returns hosts while parsing with falseParser")
 	}
 }
+
+func TestParseFileLine(t *testing.T) {
+	address, labels, err := parseFileLine("10.0.0.1:22 site=waw role=core")
+	if err != nil {
+		t.Errorf("parseFileLine returns error: %v", err)
+	}
+	if address != "10.0.0.1:22" || !reflect.DeepEqual(labels, map[string]string{"site": "waw", "role": "core"}) {
+		t.Errorf("parseFileLine returns invalid host, got: %s %v", address, labels)
+	}
+
+	if _, _, err := parseFileLine("10.0.0.1 site"); err == nil {
+		t.Error("parseFileLine doesn't return error on invalid label")
+	}
+}
@@ -2,6 +2,8 @@ package driver
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
@@ -83,6 +85,7 @@ func DBSqlLoadHosts(hostParser schema.HostParser, dbConfig *schema.DBConfig) ([]
 	}
 
 	var hosts []schema.Host
+	var invalid schema.HostErrors
 
 	rows, err := db.Query(dbConfig.Queries.GetDevices, dbConfig.IDserver)
 	if err != nil {
@@ -90,17 +93,38 @@ func DBSqlLoadHosts(hostParser schema.HostParser, dbConfig *schema.DBConfig) ([]
 	}
 	defer rows.Close()
 
+	// optional fourth column keeps host labels as JSON object
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
 	for rows.Next() {
 		host := schema.Host{}
 
-		err = rows.Scan(&host.ID, &host.IP, &host.InactiveSince)
+		var labels sql.NullString
+		fields := []interface{}{&host.ID, &host.IP, &host.InactiveSince}
+		if len(columns) > 3 {
+			fields = append(fields, &labels)
+		}
+
+		err = rows.Scan(fields...)
 		if err != nil {
 			return nil, err
 		}
-		host.IP, host.Port, err = hostParser(host.IP)
+
+		if labels.Valid && labels.String != "" {
+			if err := json.Unmarshal([]byte(labels.String), &host.Labels); err != nil {
+				invalid = append(invalid, schema.HostError{Host: host, Err: fmt.Errorf("invalid labels: %v", err)})
+				continue
+			}
+		}
+		ip, port, err := hostParser(host.IP)
 		if err != nil {
-			return nil, err
+			invalid = append(invalid, schema.HostError{Host: host, Err: err})
+			continue
 		}
+		host.IP, host.Port = ip, port
 
 		hosts = append(hosts, host)
 	}
@@ -110,6 +134,9 @@ func DBSqlLoadHosts(hostParser schema.HostParser, dbConfig *schema.DBConfig) ([]
 		return nil, err
 	}
 
+	if invalid != nil {
+		return hosts, invalid
+	}
 	return hosts, nil
 }
 
@@ -133,5 +160,12 @@ func DBSqlSavePingResult(result schema.ProbeResult, dbConfig *schema.DBConfig) e
 		return err
 	}
 
+	if a := result.Annotations; dbConfig.Queries.UpdateDeviceAnnotations != "" && a != (schema.Annotations{}) {
+		_, err = db.Exec(dbConfig.Queries.UpdateDeviceAnnotations, a.PTR, a.Country, a.City, a.ASN, a.Org, result.Host.ID)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,275 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+// sweepLimit is the maximal number of addresses of one swept network.
+const sweepLimit = 1 << 16
+
+type createDeviceRequest struct {
+	IP     string            `json:"ip"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SweepLoadHosts loads list of hosts by expanding networks given in CIDR notation,
+// network and broadcast addresses of IPv4 networks are skipped. Invalid networks are returned as HostErrors.
+func SweepLoadHosts(hostParser schema.HostParser, networks []string) ([]schema.Host, error) {
+	var hosts []schema.Host
+	var invalid schema.HostErrors
+	for _, network := range networks {
+		expanded, err := sweepNetwork(hostParser, network)
+		if err != nil {
+			invalid = append(invalid, schema.HostError{Host: schema.Host{IP: network}, Err: err})
+			continue
+		}
+		hosts = append(hosts, expanded...)
+	}
+
+	if invalid != nil {
+		return hosts, invalid
+	}
+	return hosts, nil
+}
+
+// sweepNetwork expands network into list of hosts.
+func sweepNetwork(hostParser schema.HostParser, network string) ([]schema.Host, error) {
+	if !strings.Contains(network, "/") {
+		ip, port, err := hostParser(network)
+		if err != nil {
+			return nil, err
+		}
+		return []schema.Host{{IP: ip, Port: port}}, nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("network %s exceeds limit of %d addresses", network, sweepLimit)
+	}
+
+	first, last := ipnet.IP.Mask(ipnet.Mask), lastIP(ipnet)
+	if bits == 8*net.IPv4len && bits-ones > 1 {
+		first, last = nextIP(first), prevIP(last)
+	}
+
+	var hosts []schema.Host
+	for ip := first; !ip.Equal(nextIP(last)); ip = nextIP(ip) {
+		addr, port, err := hostParser(ip.String())
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, schema.Host{IP: addr, Port: port})
+	}
+	return hosts, nil
+}
+
+func lastIP(ipnet *net.IPNet) net.IP {
+	ip := make(net.IP, len(ipnet.IP))
+	for i := range ipnet.IP {
+		ip[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+	return ip
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func prevIP(ip net.IP) net.IP {
+	prev := make(net.IP, len(ip))
+	copy(prev, ip)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			break
+		}
+	}
+	return prev
+}
+
+// formatHostLine formats host using hosts file format accepted by FileLoadHosts.
+func formatHostLine(host schema.Host) string {
+	var keys []string
+	for k := range host.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	line := host.IP
+	for _, k := range keys {
+		line += fmt.Sprintf(" %s=%s", k, host.Labels[k])
+	}
+	return line
+}
+
+// sweptHosts keeps addresses of hosts already saved by each sweep output, so continuous sweep saves only new ones.
+type sweptHosts struct {
+	mu    sync.Mutex
+	saved map[string]map[string]bool
+}
+
+var swept = sweptHosts{saved: make(map[string]map[string]bool)}
+
+// known tells if host ip was already saved to output, load is called to find hosts saved before first use of output.
+func (s *sweptHosts) known(output, ip string, load func() (map[string]bool, error)) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.saved[output] == nil {
+		saved := make(map[string]bool)
+		if load != nil {
+			var err error
+			if saved, err = load(); err != nil {
+				return false, err
+			}
+		}
+		s.saved[output] = saved
+	}
+	return s.saved[output][ip], nil
+}
+
+// save remembers host ip saved to output.
+func (s *sweptHosts) save(output, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[output][ip] = true
+}
+
+// fileHosts returns addresses of hosts listed in hosts file, missing file has no hosts.
+func fileHosts(filename string) (map[string]bool, error) {
+	hosts := make(map[string]bool)
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			hosts[fields[0]] = true
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// addressParser is HostParser returning bare address of device, so devices are matched with swept hosts.
+func addressParser(host string) (string, string, error) {
+	if ip, _, err := net.ParseCIDR(host); err == nil {
+		return ip.String(), "", nil
+	}
+	if address, _, err := net.SplitHostPort(host); err == nil {
+		return address, "", nil
+	}
+	return host, "", nil
+}
+
+// deviceHosts returns addresses of devices loaded from DB or API, devices which can't be parsed are skipped.
+func deviceHosts(devices []schema.Host, err error) (map[string]bool, error) {
+	if _, partial := err.(schema.HostErrors); err != nil && !partial {
+		return nil, err
+	}
+
+	hosts := make(map[string]bool)
+	for _, device := range devices {
+		hosts[device.IP] = true
+	}
+	return hosts, nil
+}
+
+// FileSaveSweepResult appends responsive host to file using hosts file format, hosts already listed are skipped.
+func FileSaveSweepResult(result schema.ProbeResult, filename string) error {
+	output := "file:" + filename
+	known, err := swept.known(output, result.Host.IP, func() (map[string]bool, error) { return fileHosts(filename) })
+	if err != nil || known {
+		return err
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = fmt.Fprintln(file, formatHostLine(result.Host)); err != nil {
+		return err
+	}
+	swept.save(output, result.Host.IP)
+	return nil
+}
+
+// DBSqlSaveSweepResult inserts responsive host into database as new device, hosts already being devices are skipped.
+func DBSqlSaveSweepResult(result schema.ProbeResult, dbConfig *schema.DBConfig) error {
+	known, err := swept.known("db", result.Host.IP, func() (map[string]bool, error) {
+		return deviceHosts(DBSqlLoadHosts(addressParser, dbConfig))
+	})
+	if err != nil || known {
+		return err
+	}
+
+	db := getDB(dbConfig)
+	if db.conn == nil {
+		if err := db.connect(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(dbConfig.Queries.InsertDevice, result.Host.IP, dbConfig.IDserver); err != nil {
+		return err
+	}
+	swept.save("db", result.Host.IP)
+	return nil
+}
+
+// APISaveSweepResult creates responsive host as new device using external API, hosts already being devices are skipped.
+func APISaveSweepResult(result schema.ProbeResult, apiConfig *schema.APIConfig) error {
+	known, err := swept.known("api", result.Host.IP, func() (map[string]bool, error) {
+		return deviceHosts(APILoadHosts(addressParser, apiConfig))
+	})
+	if err != nil || known {
+		return err
+	}
+
+	client := getAPIClient(apiConfig)
+	if client.authData.Token == "" {
+		if err := client.authorize(); err != nil {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(createDeviceRequest{IP: result.Host.IP, Labels: result.Host.Labels})
+	if err != nil {
+		return err
+	}
+
+	if _, err = client.request("POST", apiConfig.URL+fmt.Sprintf(apiConfig.Endpoints.CreateDevice, client.authData.IDServer), body); err != nil {
+		return err
+	}
+	swept.save("api", result.Host.IP)
+	return nil
+}
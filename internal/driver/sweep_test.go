@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+func TestSweepLoadHosts(t *testing.T) {
+	cases := []struct {
+		Network string
+		Count   int
+		First   string
+		Last    string
+	}{
+		{"10.0.0.0/22", 1022, "10.0.0.1", "10.0.3.254"},
+		{"192.168.1.10/31", 2, "192.168.1.10", "192.168.1.11"},
+		{"192.168.1.10/32", 1, "192.168.1.10", "192.168.1.10"},
+		{"192.168.1.10", 1, "192.168.1.10", "192.168.1.10"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Network, func(t *testing.T) {
+			hosts, err := SweepLoadHosts(trueParser, []string{tc.Network})
+			if err != nil {
+				t.Fatalf("SweepLoadHosts returns error: %v", err)
+			}
+			if len(hosts) != tc.Count || hosts[0].IP != tc.First || hosts[len(hosts)-1].IP != tc.Last {
+				t.Errorf("expected %d hosts %s - %s, got: %d", tc.Count, tc.First, tc.Last, len(hosts))
+			}
+		})
+	}
+
+	if _, err := SweepLoadHosts(trueParser, []string{"10.0.0.0/8"}); err == nil {
+		t.Error("SweepLoadHosts doesn't return error on too large network")
+	}
+
+	hosts, err := SweepLoadHosts(trueParser, []string{"10.0.0.0/8", "192.168.1.10/31"})
+	if invalid, ok := err.(schema.HostErrors); !ok || len(invalid) != 1 || invalid[0].Host.IP != "10.0.0.0/8" {
+		t.Errorf("SweepLoadHosts doesn't return invalid network as HostErrors, got: %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Errorf("SweepLoadHosts doesn't return hosts of valid networks, got: %v", hosts)
+	}
+}
+
+func TestAPISaveSweepResult(t *testing.T) {
+	var created []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/auth":
+			fmt.Fprintln(w, `{"token": "some_token", "id_server": 1}`)
+		case r.Method == "GET":
+			fmt.Fprintln(w, `[{"id": 1, "ip": "10.0.0.1"}, {"id": 2, "ip": "10.0.0.3:22"}]`)
+		case r.Method == "POST":
+			var device createDeviceRequest
+			json.NewDecoder(r.Body).Decode(&device)
+			created = append(created, device.IP)
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer ts.Close()
+
+	swept = sweptHosts{saved: make(map[string]map[string]bool)}
+	config := &schema.APIConfig{URL: ts.URL, Endpoints: schema.APIEndpoints{
+		Authenticate: "/auth",
+		GetDevices:   "/devices/%d",
+		CreateDevice: "/devices/%d",
+	}}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.2"} {
+		if err := APISaveSweepResult(schema.ProbeResult{Host: schema.Host{IP: ip}}, config); err != nil {
+			t.Fatalf("APISaveSweepResult returns error: %v", err)
+		}
+	}
+	if expected := []string{"10.0.0.2"}; !reflect.DeepEqual(created, expected) {
+		t.Errorf("expected only new devices %v created, got: %v", expected, created)
+	}
+}
+
+func TestFormatHostLine(t *testing.T) {
+	line := formatHostLine(schema.Host{IP: "10.0.0.1", Labels: map[string]string{"ports": "22,443", "a": "b"}})
+	if line != "10.0.0.1 a=b ports=22,443" {
+		t.Errorf("invalid hosts file line, got: %s", line)
+	}
+
+	address, labels, err := parseFileLine(line)
+	if err != nil || address != "10.0.0.1" || labels["ports"] != "22,443" {
+		t.Errorf("hosts file line can't be loaded back, got: %s %v %v", address, labels, err)
+	}
+}
+
+func TestFileSaveSweepResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "hosts.txt")
+	if err := ioutil.WriteFile(filename, []byte("10.0.0.1 ports=22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.2"} {
+		if err := FileSaveSweepResult(schema.ProbeResult{Host: schema.Host{IP: ip}}, filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "10.0.0.1 ports=22\n10.0.0.2\n" {
+		t.Errorf("known hosts should be skipped, got: %q", content)
+	}
+}
@@ -0,0 +1,135 @@
+package enricher
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+	maxminddb "github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	defaultTimeout  = 2 * time.Second
+	defaultCacheTTL = 24 * time.Hour
+)
+
+// record covers fields of GeoIP2/GeoLite2 City, Country and ASN databases.
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN uint   `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+type entry struct {
+	annotations schema.Annotations
+	expires     time.Time
+}
+
+// Enricher annotates IP addresses using reverse DNS and MaxMind format databases, annotations are cached per IP.
+type Enricher struct {
+	reverseDNS bool
+	timeout    time.Duration
+	cacheTTL   time.Duration
+	databases  []*maxminddb.Reader
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+// NewEnricher returns Enricher configured by EnrichmentConfig, opening all configured databases.
+func NewEnricher(config schema.EnrichmentConfig) (*Enricher, error) {
+	e := &Enricher{
+		reverseDNS: config.ReverseDNS,
+		timeout:    config.Timeout.Duration,
+		cacheTTL:   config.CacheTTL.Duration,
+		lookupAddr: net.DefaultResolver.LookupAddr,
+		cache:      make(map[string]entry),
+	}
+	if e.timeout == 0 {
+		e.timeout = defaultTimeout
+	}
+	if e.cacheTTL == 0 {
+		e.cacheTTL = defaultCacheTTL
+	}
+
+	for _, file := range config.Databases {
+		db, err := maxminddb.Open(file)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		e.databases = append(e.databases, db)
+	}
+	return e, nil
+}
+
+// Close closes opened databases.
+func (e *Enricher) Close() {
+	for _, db := range e.databases {
+		db.Close()
+	}
+}
+
+// Annotate returns annotations of IP address, fields not found in any source are left empty.
+func (e *Enricher) Annotate(ip string) schema.Annotations {
+	e.mu.Lock()
+	cached, ok := e.cache[ip]
+	e.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.annotations
+	}
+
+	var annotations schema.Annotations
+	if e.reverseDNS {
+		annotations.PTR = e.reverse(ip)
+	}
+
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, db := range e.databases {
+			var r record
+			if err := db.Lookup(addr, &r); err != nil {
+				continue
+			}
+
+			// first database providing field wins
+			if annotations.Country == "" {
+				annotations.Country = r.Country.ISOCode
+			}
+			if annotations.City == "" {
+				annotations.City = r.City.Names["en"]
+			}
+			if annotations.ASN == 0 {
+				annotations.ASN = r.ASN
+			}
+			if annotations.Org == "" {
+				annotations.Org = r.Org
+			}
+		}
+	}
+
+	e.mu.Lock()
+	e.cache[ip] = entry{annotations: annotations, expires: time.Now().Add(e.cacheTTL)}
+	e.mu.Unlock()
+
+	return annotations
+}
+
+func (e *Enricher) reverse(ip string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	names, err := e.lookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
@@ -0,0 +1,41 @@
+package enricher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+func TestAnnotate(t *testing.T) {
+	e, err := NewEnricher(schema.EnrichmentConfig{ReverseDNS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lookups int
+	e.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		lookups++
+		if addr == "192.0.2.1" {
+			return []string{"host.example.com."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	if a := e.Annotate("192.0.2.1"); a.PTR != "host.example.com" {
+		t.Errorf("expected PTR host.example.com, got: %v", a)
+	}
+	if a := e.Annotate("192.0.2.1"); a.PTR != "host.example.com" || lookups != 1 {
+		t.Errorf("expected cached annotations, got: %v after %d lookups", a, lookups)
+	}
+	if a := e.Annotate("192.0.2.2"); a != (schema.Annotations{}) {
+		t.Errorf("expected empty annotations, got: %v", a)
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	if _, err := NewEnricher(schema.EnrichmentConfig{Databases: []string{"invalid_file_name"}}); err == nil {
+		t.Error("NewEnricher doesn't return error on non existing database")
+	}
+}
@@ -0,0 +1,111 @@
+package pacer
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+const (
+	defaultSubnetPrefix  = 24
+	defaultSubnetPrefix6 = 64
+
+	// maxSubnets is the number of tracked subnets above which idle ones are forgotten
+	maxSubnets = 4096
+)
+
+// Pacer spreads packets sent by all workers evenly in time, so bursts don't trip ICMP rate limits of routers
+// on the way. Send slots are reserved in order of requests, packets are spaced by global limit and additionally
+// by limit of their destination subnet.
+type Pacer struct {
+	interval       time.Duration
+	subnetInterval time.Duration
+	mask           net.IPMask
+	mask6          net.IPMask
+
+	mu      sync.Mutex
+	next    time.Time
+	subnets map[string]time.Time
+}
+
+// NewPacer returns Pacer configured by PacingConfig, zero rates are unlimited.
+func NewPacer(config schema.PacingConfig) *Pacer {
+	p := &Pacer{
+		mask:    net.CIDRMask(defaultSubnetPrefix, 8*net.IPv4len),
+		mask6:   net.CIDRMask(defaultSubnetPrefix6, 8*net.IPv6len),
+		subnets: make(map[string]time.Time),
+	}
+	if config.Rate > 0 {
+		p.interval = time.Second / time.Duration(config.Rate)
+	}
+	if config.SubnetRate > 0 {
+		p.subnetInterval = time.Second / time.Duration(config.SubnetRate)
+	}
+	if config.SubnetPrefix > 0 && config.SubnetPrefix <= 8*net.IPv4len {
+		p.mask = net.CIDRMask(config.SubnetPrefix, 8*net.IPv4len)
+	}
+	if config.SubnetPrefix6 > 0 && config.SubnetPrefix6 <= 8*net.IPv6len {
+		p.mask6 = net.CIDRMask(config.SubnetPrefix6, 8*net.IPv6len)
+	}
+	return p
+}
+
+// Wait blocks until packet may be sent to ip.
+func (p *Pacer) Wait(ip string) {
+	if slot := p.reserve(ip, time.Now()); slot > 0 {
+		time.Sleep(slot)
+	}
+}
+
+// reserve reserves the first send slot to ip allowed by limits and returns how long to wait for it.
+func (p *Pacer) reserve(ip string, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot := now
+	if p.next.After(slot) {
+		slot = p.next
+	}
+	p.next = slot.Add(p.interval)
+
+	// packet delayed by its subnet limit keeps global slot, so it doesn't hold up packets to other subnets
+	subnet := p.subnet(ip)
+	if subnet != "" {
+		if next := p.subnets[subnet]; next.After(slot) {
+			slot = next
+		}
+		if len(p.subnets) >= maxSubnets {
+			p.forget(now)
+		}
+		p.subnets[subnet] = slot.Add(p.subnetInterval)
+	}
+
+	return slot.Sub(now)
+}
+
+// subnet returns destination subnet of ip, or empty string if subnets are not limited.
+func (p *Pacer) subnet(ip string) string {
+	if p.subnetInterval == 0 {
+		return ""
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ip
+	}
+	if addr4 := addr.To4(); addr4 != nil {
+		return addr4.Mask(p.mask).String()
+	}
+	return addr.Mask(p.mask6).String()
+}
+
+// forget removes subnets with no packets scheduled after now.
+func (p *Pacer) forget(now time.Time) {
+	for subnet, next := range p.subnets {
+		if !next.After(now) {
+			delete(p.subnets, subnet)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package pacer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+func TestGlobalRate(t *testing.T) {
+	p := NewPacer(schema.PacingConfig{Rate: 100})
+	now := time.Now()
+
+	for i, ip := range []string{"192.0.2.1", "198.51.100.1", "2001:db8::1", "192.0.2.1"} {
+		if slot := p.reserve(ip, now); slot != time.Duration(i)*10*time.Millisecond {
+			t.Errorf("packet %d to %s expected in %v, got: %v", i, ip, time.Duration(i)*10*time.Millisecond, slot)
+		}
+	}
+
+	// budget isn't accumulated while idle
+	if slot := p.reserve("192.0.2.1", now.Add(time.Second)); slot != 0 {
+		t.Errorf("packet after idle second expected immediately, got: %v", slot)
+	}
+}
+
+func TestSubnetRate(t *testing.T) {
+	p := NewPacer(schema.PacingConfig{SubnetRate: 10, SubnetPrefix6: 48})
+	now := time.Now()
+
+	cases := []struct {
+		ip   string
+		slot time.Duration
+	}{
+		{"192.0.2.1", 0},
+		{"192.0.2.200", 100 * time.Millisecond},
+		{"198.51.100.1", 0},
+		{"192.0.2.1", 200 * time.Millisecond},
+		{"2001:db8:0:1::1", 0},
+		{"2001:db8:0:2::1", 100 * time.Millisecond},
+		{"2001:db8:1::1", 0},
+	}
+	for _, c := range cases {
+		if slot := p.reserve(c.ip, now); slot != c.slot {
+			t.Errorf("packet to %s expected in %v, got: %v", c.ip, c.slot, slot)
+		}
+	}
+}
+
+func TestForgetSubnets(t *testing.T) {
+	p := NewPacer(schema.PacingConfig{SubnetRate: 1000, SubnetPrefix: 32})
+	now := time.Now()
+
+	for i := 0; i < maxSubnets; i++ {
+		p.reserve(fmt.Sprintf("10.0.%d.%d", i/256, i%256), now)
+	}
+	p.reserve("192.0.2.1", now.Add(time.Second))
+	if len(p.subnets) != 1 {
+		t.Errorf("expected idle subnets forgotten, got: %d subnets", len(p.subnets))
+	}
+}
+
+func TestGlobalAndSubnetRate(t *testing.T) {
+	p := NewPacer(schema.PacingConfig{Rate: 100, SubnetRate: 10})
+	now := time.Now()
+
+	cases := []struct {
+		ip   string
+		slot time.Duration
+	}{
+		{"192.0.2.1", 0},
+		{"192.0.2.2", 100 * time.Millisecond},
+		{"198.51.100.1", 20 * time.Millisecond},
+		{"203.0.113.1", 30 * time.Millisecond},
+	}
+	for _, c := range cases {
+		if slot := p.reserve(c.ip, now); slot != c.slot {
+			t.Errorf("packet to %s expected in %v, got: %v", c.ip, c.slot, slot)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultTimeout = 2 * time.Second
+	defaultMinTTL  = 10 * time.Second
+	defaultMaxTTL  = time.Hour
+)
+
+type entry struct {
+	ip      string
+	err     error
+	expires time.Time
+}
+
+// Resolver resolves host names using configured DNS servers (or system resolver) and caches answers
+// as long as their TTLs allow.
+type Resolver struct {
+	servers []string
+	timeout time.Duration
+	minTTL  time.Duration
+	maxTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+// NewResolver returns Resolver configured by ResolverConfig.
+func NewResolver(config schema.ResolverConfig) *Resolver {
+	r := &Resolver{
+		timeout: config.Timeout.Duration,
+		minTTL:  config.MinTTL.Duration,
+		maxTTL:  config.MaxTTL.Duration,
+		cache:   make(map[string]entry),
+	}
+	if r.timeout == 0 {
+		r.timeout = defaultTimeout
+	}
+	if r.minTTL == 0 {
+		r.minTTL = defaultMinTTL
+	}
+	if r.maxTTL == 0 {
+		r.maxTTL = defaultMaxTTL
+	}
+
+	for _, server := range config.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		r.servers = append(r.servers, server)
+	}
+	return r
+}
+
+// Resolve returns IP address of host name, IP addresses are returned as is.
+func (r *Resolver) Resolve(name string) (string, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return ip.String(), nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[name]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.ip, cached.err
+	}
+
+	ip, ttl, err := r.lookup(name)
+	if ttl < r.minTTL {
+		ttl = r.minTTL
+	}
+	if ttl > r.maxTTL {
+		ttl = r.maxTTL
+	}
+
+	r.mu.Lock()
+	r.cache[name] = entry{ip: ip, err: err, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+
+	return ip, err
+}
+
+func (r *Resolver) lookup(name string) (string, time.Duration, error) {
+	if len(r.servers) == 0 {
+		return r.lookupSystem(name)
+	}
+
+	var lastErr error
+	var lastTTL time.Duration
+	for _, server := range r.servers {
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			ips, ttl, err := r.query(server, name, qtype)
+			if err != nil {
+				lastErr, lastTTL = err, ttl
+				break
+			}
+			if len(ips) > 0 {
+				return ips[0].String(), ttl, nil
+			}
+			lastErr, lastTTL = fmt.Errorf("no such host: %s", name), ttl
+		}
+	}
+	return "", lastTTL, lastErr
+}
+
+// lookupSystem uses system resolver, which doesn't expose TTL, so answers are cached for minimal TTL.
+func (r *Resolver) lookupSystem(name string) (string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// prefer IPv4 the same way as net.ResolveIPAddr does
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP.String(), 0, nil
+		}
+	}
+	return addrs[0].IP.String(), 0, nil
+}
+
+func (r *Resolver) query(server, name string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	request := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := request.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conn, err := net.DialTimeout("udp", server, r.timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(r.timeout))
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, 0, err
+	}
+
+	var response dnsmessage.Message
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := response.Unpack(buf[:n]); err != nil || response.ID != id {
+			// not our answer, wait for next one
+			continue
+		}
+		break
+	}
+
+	switch response.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, r.negativeTTL(response), fmt.Errorf("no such host: %s", strings.TrimSuffix(name, "."))
+	default:
+		return nil, 0, fmt.Errorf("DNS server %s responded %v", server, response.RCode)
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for _, answer := range response.Answers {
+		var ip net.IP
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue
+		}
+		if len(ips) == 0 || answer.Header.TTL < ttl {
+			ttl = answer.Header.TTL
+		}
+		ips = append(ips, ip)
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// negativeTTL returns time to cache failed answer, taken from SOA record as described by RFC 2308.
+func (r *Resolver) negativeTTL(response dnsmessage.Message) time.Duration {
+	for _, authority := range response.Authorities {
+		if soa, ok := authority.Body.(*dnsmessage.SOAResource); ok {
+			ttl := soa.MinTTL
+			if authority.Header.TTL < ttl {
+				ttl = authority.Header.TTL
+			}
+			return time.Duration(ttl) * time.Second
+		}
+	}
+	return 0
+}
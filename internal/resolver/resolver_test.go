@@ -0,0 +1,90 @@
+package resolver
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsServer runs local DNS server answering A queries for example.com, returns server address and queries counter.
+func dnsServer(t *testing.T, ttl uint32) (string, *int32, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queries int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&queries, 1)
+
+			var request dnsmessage.Message
+			if err := request.Unpack(buf[:n]); err != nil {
+				continue
+			}
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: request.ID, Response: true, RCode: dnsmessage.RCodeNameError},
+				Questions: request.Questions,
+			}
+			question := request.Questions[0]
+			if question.Name.String() == "example.com." && question.Type == dnsmessage.TypeA {
+				response.RCode = dnsmessage.RCodeSuccess
+				response.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+					Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+				}}
+			}
+
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), &queries, func() { conn.Close() }
+}
+
+func TestResolve(t *testing.T) {
+	server, queries, stop := dnsServer(t, 1)
+	defer stop()
+
+	r := NewResolver(schema.ResolverConfig{
+		Servers: []string{server},
+		Timeout: schema.Duration{Duration: time.Second},
+		MinTTL:  schema.Duration{Duration: time.Millisecond},
+	})
+
+	ip, err := r.Resolve("example.com")
+	if err != nil || ip != "192.0.2.1" {
+		t.Fatalf("expected 192.0.2.1, got: %s, error: %v", ip, err)
+	}
+
+	if ip, _ := r.Resolve("example.com"); ip != "192.0.2.1" || atomic.LoadInt32(queries) != 1 {
+		t.Errorf("expected cached answer, got: %s after %d queries", ip, atomic.LoadInt32(queries))
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if ip, _ := r.Resolve("example.com"); ip != "192.0.2.1" || atomic.LoadInt32(queries) != 2 {
+		t.Errorf("expected answer resolved again after TTL, got: %s after %d queries", ip, atomic.LoadInt32(queries))
+	}
+
+	if _, err := r.Resolve("invalid.example.com"); err == nil {
+		t.Error("expected error resolving unknown host")
+	}
+
+	if ip, err := r.Resolve("10.0.0.1"); err != nil || ip != "10.0.0.1" {
+		t.Errorf("expected IP address returned as is, got: %s, error: %v", ip, err)
+	}
+}
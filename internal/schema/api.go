@@ -14,4 +14,5 @@ type APIEndpoints struct {
 	Authenticate string
 	GetDevices   string `toml:"get_devices"`
 	UpdateDevice string `toml:"update_device"`
+	CreateDevice string `toml:"create_device"`
 }
@@ -11,10 +11,14 @@ import (
 
 // Host definition.
 type Host struct {
-	ID            int            `json:"id"`
-	IP            string         `json:"ip"`
-	Port          string         `json:"port"`
-	InactiveSince sql.NullString `json:"inactive_since"`
+	ID            int               `json:"id"`
+	IP            string            `json:"ip"`
+	Hostname      string            `json:"hostname,omitempty"`
+	Port          string            `json:"port"`
+	InactiveSince sql.NullString    `json:"inactive_since"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Source        string            `json:"-"`
+	Confirmations int               `json:"-"`
 }
 
 // UnmarshalJSON is needed for unmarshal sq.NullString value used by SQL driver.
@@ -34,10 +38,40 @@ func (h *Host) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// HostError describes host entry which can't be parsed.
+type HostError struct {
+	Host Host
+	Err  error
+}
+
+func (e HostError) Error() string {
+	if e.Host.ID != 0 {
+		return fmt.Sprintf("host id %d: %v", e.Host.ID, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// HostErrors is list of invalid host entries collected by HostsLoader.
+type HostErrors []HostError
+
+func (e HostErrors) Error() string {
+	var list []string
+	for _, hostError := range e {
+		list = append(list, hostError.Error())
+	}
+	return strings.Join(list, ", ")
+}
+
+// HostResolver resolves host name to IP address.
+type HostResolver interface {
+	Resolve(name string) (string, error)
+}
+
 // HostParser validates input string as proper host and converts it to format accepted by probe.
 type HostParser func(string) (string, string, error)
 
 // HostsLoader returns list of hosts needed by probe workers, throws error in case failure of any validation.
+// Entries which failed validation are returned as HostErrors together with list of valid hosts.
 type HostsLoader func(HostParser) ([]Host, error)
 
 // HostsCleaner cleanups handlers, connections, open sockets, files etc. used by Loader/Saver/Parser.
@@ -45,34 +79,142 @@ type HostsCleaner func()
 
 // Hosts defines list of hosts to probe.
 type Hosts struct {
-	hosts       []Host
-	defaultPort int
+	hosts        []Host
+	index        map[string]int
+	invalid      []HostError
+	duplicates   int
+	defaultPort  int
+	defaultPorts string
+	lenient      bool
+	mode         string
+	priority     []string
+	resolver     HostResolver
 }
 
 func (h *Hosts) parseHost(host string) (string, string, error) {
-	list := strings.Split(host, ":")
-	if len(list) > 2 {
-		return "", "", fmt.Errorf(fmt.Sprintf("Host invalid format: %s", host))
+	address, port, err := splitHost(host)
+	if err != nil {
+		return "", "", err
 	}
 
-	var port string
-	if len(list) == 2 {
-		port = list[1]
-	} else {
+	if port != "" {
+		if _, err := ParsePorts(port); err != nil {
+			return "", "", fmt.Errorf("Host invalid port: %s, %v", host, err)
+		}
+	} else if h.defaultPorts != "" {
+		port = h.defaultPorts
+	} else if h.defaultPort != 0 || portless(h.mode) {
 		port = strconv.Itoa(h.defaultPort)
+	} else {
+		return "", "", fmt.Errorf("Host without port: %s", host)
 	}
 
-	ipaddr, err := net.ResolveIPAddr("ip", list[0])
-	if err == nil {
-		return ipaddr.IP.String(), port, nil
+	// zone of IPv6 link-local address is kept, e.g. "fe80::1%eth0"
+	var zone string
+	if i := strings.LastIndex(address, "%"); i > 0 && strings.Contains(address, ":") {
+		address, zone = address[:i], address[i:]
 	}
 
-	IP, _, err := net.ParseCIDR(host)
-	if err == nil {
-		return IP.String(), port, nil
+	IP := net.ParseIP(address)
+	if IP == nil {
+		IP, _, _ = net.ParseCIDR(address)
+	}
+	if IP != nil {
+		// ICMP timestamp messages exist only in ICMP for IPv4
+		if h.mode == "timestamp" && IP.To4() == nil {
+			return "", "", fmt.Errorf("Host is not IPv4, required by timestamp mode: %s", host)
+		}
+		return IP.String() + zone, port, nil
+	}
+
+	// host names are resolved later by HostResolver
+	if zone == "" && isHostname(address) {
+		return address, port, nil
 	}
 
-	return "", "", fmt.Errorf(fmt.Sprintf("Can't resolve host: %s", host))
+	return "", "", fmt.Errorf("Can't resolve host: %s", host)
+}
+
+// splitHost splits host into address and optional port, IPv6 address with port has to be in brackets,
+// e.g. "[2001:db8::1]:22", address without brackets containing many colons is IPv6 address without port.
+func splitHost(host string) (string, string, error) {
+	if strings.HasPrefix(host, "[") {
+		if strings.HasSuffix(host, "]") {
+			return host[1 : len(host)-1], "", nil
+		}
+		address, port, err := net.SplitHostPort(host)
+		if err != nil || port == "" {
+			return "", "", fmt.Errorf("Host invalid format: %s", host)
+		}
+		return address, port, nil
+	}
+
+	switch strings.Count(host, ":") {
+	case 0:
+		return host, "", nil
+	case 1:
+		list := strings.Split(host, ":")
+		return list[0], list[1], nil
+	default:
+		return host, "", nil
+	}
+}
+
+// maxPorts is the maximal number of ports of one host.
+const maxPorts = 1024
+
+// ParsePorts expands list of ports and ports ranges, e.g. "22,80,443" or "8000-8100".
+func ParsePorts(ports string) ([]string, error) {
+	var list []string
+	for _, item := range strings.Split(ports, ",") {
+		bounds := strings.SplitN(item, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first <= 0 || first > 65535 {
+			return nil, fmt.Errorf("wrong port number: %s", item)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first || last > 65535 {
+				return nil, fmt.Errorf("wrong ports range: %s", item)
+			}
+		}
+
+		if len(list)+last-first+1 > maxPorts {
+			return nil, fmt.Errorf("too many ports, limit is %d", maxPorts)
+		}
+		for port := first; port <= last; port++ {
+			list = append(list, strconv.Itoa(port))
+		}
+	}
+	return list, nil
+}
+
+// isHostname validates host name syntax as described by RFC 1123.
+func isHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	// top level domain can't be numeric, otherwise it's rather malformed IP address
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return false
+	}
+	return true
 }
 
 // Get list of hosts.
@@ -87,20 +229,138 @@ func (h *Hosts) Init(p int) {
 	}
 }
 
+// SetDefaultPorts sets list of ports used by hosts without explicitly specified port, overrides port set by Init.
+func (h *Hosts) SetDefaultPorts(ports string) {
+	h.defaultPorts = ports
+}
+
+// SetLenient sets the way of handling invalid hosts.
+// false means Add fails if any of loaded hosts is invalid.
+// true means invalid hosts are skipped and collected, see Invalid.
+func (h *Hosts) SetLenient(lenient bool) {
+	h.lenient = lenient
+}
+
+// SetDeduplication sets probe mode used to detect duplicated hosts and priority of sources used to merge them.
+// Host loaded from source listed earlier in priority wins, sources not listed are less important than listed ones,
+// in case of tie the first loaded host wins.
+func (h *Hosts) SetDeduplication(mode string, priority []string) {
+	h.mode = mode
+	h.priority = priority
+}
+
+// SetResolver sets resolver used to translate host names into IP addresses.
+func (h *Hosts) SetResolver(resolver HostResolver) {
+	h.resolver = resolver
+}
+
+// Duplicates returns number of hosts collapsed since last Reset.
+func (h *Hosts) Duplicates() int {
+	return h.duplicates
+}
+
+// Invalid returns list of hosts skipped in lenient mode.
+func (h *Hosts) Invalid() []HostError {
+	return h.invalid
+}
+
 // Reset list of hosts.
 func (h *Hosts) Reset() {
 	h.hosts = nil
+	h.index = nil
+	h.invalid = nil
+	h.duplicates = 0
 }
 
 // Add hosts using HostsLoader function.
 func (h *Hosts) Add(loader HostsLoader) error {
 	hosts, err := loader(h.parseHost)
 	if err != nil {
-		return err
+		invalid, ok := err.(HostErrors)
+		if !ok || !h.lenient {
+			return err
+		}
+		h.invalid = append(h.invalid, invalid...)
 	}
 
+	if h.index == nil {
+		h.index = make(map[string]int)
+	}
 	for _, host := range hosts {
+		host = h.resolve(host)
+		key := h.key(host)
+		if i, ok := h.index[key]; ok {
+			h.hosts[i] = h.merge(h.hosts[i], host)
+			h.duplicates++
+			continue
+		}
+		h.index[key] = len(h.hosts)
 		h.hosts = append(h.hosts, host)
 	}
 	return nil
 }
+
+// resolve keeps original host name and sets IP address if name can be resolved,
+// otherwise IP is left empty and failure is reported by probe.
+func (h *Hosts) resolve(host Host) Host {
+	if h.resolver == nil || net.ParseIP(host.IP) != nil {
+		return host
+	}
+
+	if host.Hostname == "" {
+		host.Hostname = host.IP
+	}
+	host.IP, _ = h.resolver.Resolve(host.Hostname)
+	return host
+}
+
+// portless tells if probe of mode probes whole host so port doesn't matter, e.g. ping, ratelimit, timestamp and arp.
+func portless(mode string) bool {
+	return mode == "" || mode == "ping" || mode == "ratelimit" || mode == "timestamp" || mode == "arp"
+}
+
+// key identifies probed service, hosts are identified by address only if port doesn't matter.
+func (h *Hosts) key(host Host) string {
+	address := host.IP
+	if address == "" {
+		address = host.Hostname
+	}
+	if portless(h.mode) {
+		return address
+	}
+	return net.JoinHostPort(address, host.Port)
+}
+
+func (h *Hosts) rank(source string) int {
+	for i, s := range h.priority {
+		if s == source {
+			return i
+		}
+	}
+	return len(h.priority)
+}
+
+// merge duplicated hosts, winner keeps its ID and labels, loser fills missing ones.
+func (h *Hosts) merge(current, duplicate Host) Host {
+	winner, loser := current, duplicate
+	if h.rank(duplicate.Source) < h.rank(current.Source) {
+		winner, loser = duplicate, current
+	}
+
+	if winner.ID == 0 {
+		winner.ID = loser.ID
+		winner.InactiveSince = loser.InactiveSince
+	}
+
+	if len(loser.Labels) > 0 {
+		labels := make(map[string]string)
+		for k, v := range loser.Labels {
+			labels[k] = v
+		}
+		for k, v := range winner.Labels {
+			labels[k] = v
+		}
+		winner.Labels = labels
+	}
+	return winner
+}
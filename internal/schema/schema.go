@@ -1,8 +1,13 @@
 package schema
 
 import (
+	"net"
+	"regexp"
 	"sync"
+	"text/template"
 	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 // Worker specifies worker type function.
@@ -22,23 +27,361 @@ func (d *Duration) UnmarshalText(text []byte) error {
 
 // ProbeConfig sets up go-ping configuration.
 type ProbeConfig struct {
-	Privileged  bool
-	Mode        string
-	Protocol    string
-	Interval    Duration
-	Count       int
-	Timeout     Duration
-	DefaultPort int `toml:"default_netcat_port"`
-	Worker      Worker
+	Privileged   bool
+	Mode         string
+	Protocol     string
+	Interval     Duration
+	Count        int
+	Timeout      Duration
+	DefaultPort  int    `toml:"default_netcat_port"`
+	DefaultPorts string `toml:"default_ports"`
+	Ping         PingConfig
+	RateLimit    RateLimitConfig `toml:"rate_limit"`
+	SSH          SSHConfig
+	SNMP         SNMPConfig
+	NTP          NTPConfig
+	SQL          SQLConfig
+	GRPC         GRPCConfig
+	DHCP         DHCPConfig
+	RADIUS       RADIUSConfig
+	ARP          ARPConfig
+	Worker       Worker
+}
+
+// PayloadPattern fills payload of echo request with sequence number seq.
+type PayloadPattern interface {
+	Fill(b []byte, seq int)
+}
+
+// PingConfig specifies payload of echo requests, replies with payload different from sent are counted as corrupted,
+// and if round-trip times are computed using kernel receive timestamps (Linux only).
+type PingConfig struct {
+	Size             int
+	Pattern          string
+	KernelTimestamps bool           `toml:"kernel_timestamps"`
+	Payload          PayloadPattern `toml:"-"`
+}
+
+// RateLimitConfig specifies burst of ratelimit probe, Burst echo requests sent every BurstInterval. Host is reported
+// as ICMP rate-limited if loss of burst is at least Threshold percentage points higher than loss of spaced train.
+type RateLimitConfig struct {
+	Burst         int
+	BurstInterval Duration `toml:"burst_interval"`
+	Threshold     float64
+}
+
+// SSHConfig specifies host key verification of ssh probe, expected fingerprint may be set per host
+// by label ssh_fingerprint, otherwise host key is looked up in KnownHosts file.
+type SSHConfig struct {
+	User       string
+	KnownHosts string `toml:"known_hosts"`
+
+	// HostKeyCallback verifies host keys using KnownHosts file loaded at startup.
+	HostKeyCallback ssh.HostKeyCallback `toml:"-"`
+}
+
+// UptimeTracker keeps last known uptime of hosts, returns previous uptime and true if host was rebooted.
+type UptimeTracker interface {
+	Update(host string, uptime time.Duration) (time.Duration, bool)
+}
+
+// SNMPConfig specifies SNMP GET requests of snmp probe, by default sysUpTime and sysName are requested.
+type SNMPConfig struct {
+	Version   string
+	Community string
+	OIDs      []string      `toml:"oids"`
+	Uptimes   UptimeTracker `toml:"-"`
+}
+
+// NTPConfig specifies thresholds of absolute clock offset reported by ntp probe as WARNING and CRITICAL status.
+type NTPConfig struct {
+	WarningOffset  Duration `toml:"warning_offset"`
+	CriticalOffset Duration `toml:"critical_offset"`
+}
+
+// SQLConfig specifies database connections of sql probe, DSN is template executed for each Host,
+// e.g. "postgres://monitor@{{.IP}}:{{.Port}}/{{index .Labels \"db\"}}?sslmode=disable".
+type SQLConfig struct {
+	Driver   string
+	DSN      string `toml:"dsn"`
+	Query    string
+	Template *template.Template `toml:"-"`
+}
+
+// GRPCConfig specifies health checks of grpc probe, service name may be set per host by label grpc_service.
+type GRPCConfig struct {
+	Service            string
+	TLS                bool `toml:"tls"`
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+}
+
+// DHCPConfig specifies DHCPDISCOVER sent by dhcp probe, with Giaddr set probe acts as relay agent
+// and receives offers on port 67, otherwise on client port 68.
+type DHCPConfig struct {
+	ClientMAC    string           `toml:"client_mac"`
+	Giaddr       string           `toml:"giaddr"`
+	LocalPort    int              `toml:"local_port"`
+	HardwareAddr net.HardwareAddr `toml:"-"`
+	RelayAddr    net.IP           `toml:"-"`
+}
+
+// RADIUSConfig specifies test credentials and shared secret of radius probe, empty Password and Secret
+// are taken from UPING_RADIUS_PASSWORD and UPING_RADIUS_SECRET environment variables.
+type RADIUSConfig struct {
+	User     string
+	Password string
+	Secret   string
+}
+
+// ARPConfig specifies OUI database (IEEE oui.txt or Wireshark manuf) used by arp probe to find vendors of MAC addresses.
+type ARPConfig struct {
+	OUIFile string            `toml:"oui_file"`
+	Vendors map[string]string `toml:"-"`
+}
+
+// Statuses of ProbeResult.
+const (
+	StatusUnresolved   = "UNRESOLVED"
+	StatusResolveError = "RESOLVE_ERROR"
+	StatusWarning      = "WARNING"
+	StatusCritical     = "CRITICAL"
+	StatusRebooted     = "REBOOTED"
+	StatusRateLimited  = "RATE_LIMITED"
+)
+
+// Annotations describe probed IP address, e.g. by reverse DNS and GeoIP/ASN database.
+type Annotations struct {
+	PTR     string `json:"ptr,omitempty"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"`
+}
+
+// Annotator returns Annotations of IP address.
+type Annotator interface {
+	Annotate(ip string) Annotations
+}
+
+// ServiceResult keeps result of probing one service port of host.
+type ServiceResult struct {
+	Port       string  `json:"port"`
+	Reachable  bool    `json:"reachable"`
+	Time       float64 `json:"time"`
+	Error      string  `json:"error,omitempty"`
+	Banner     string  `json:"banner,omitempty"`
+	FailedStep int     `json:"failed_step,omitempty"`
+}
+
+// SSHResult keeps result of SSH handshake and host key verification.
+type SSHResult struct {
+	ServerVersion string  `json:"server_version,omitempty"`
+	KeyType       string  `json:"key_type,omitempty"`
+	Fingerprint   string  `json:"fingerprint,omitempty"`
+	KeyVerified   bool    `json:"key_verified"`
+	HandshakeTime float64 `json:"handshake_time"`
+}
+
+// SNMPResult keeps values returned by SNMP agent, Uptime is in seconds.
+type SNMPResult struct {
+	SysName  string            `json:"sys_name,omitempty"`
+	Uptime   float64           `json:"uptime,omitempty"`
+	Rebooted bool              `json:"rebooted,omitempty"`
+	Values   map[string]string `json:"values,omitempty"`
+}
+
+// NTPResult keeps clock offset and round-trip delay (in seconds) of NTP server with its stratum and reference ID.
+type NTPResult struct {
+	Offset      float64 `json:"offset"`
+	Delay       float64 `json:"delay"`
+	Stratum     uint8   `json:"stratum"`
+	ReferenceID string  `json:"reference_id,omitempty"`
+}
+
+// SQLResult keeps average time (in seconds) of connecting to database and running test query.
+type SQLResult struct {
+	ConnectTime float64 `json:"connect_time"`
+	QueryTime   float64 `json:"query_time"`
+}
+
+// GRPCResult keeps serving status returned by gRPC health service.
+type GRPCResult struct {
+	Service string `json:"service,omitempty"`
+	Status  string `json:"status"`
+}
+
+// DHCPResult keeps address and lease options offered by DHCP server, LeaseTime is in seconds.
+type DHCPResult struct {
+	OfferedIP  string   `json:"offered_ip"`
+	ServerID   string   `json:"server_id,omitempty"`
+	LeaseTime  float64  `json:"lease_time,omitempty"`
+	SubnetMask string   `json:"subnet_mask,omitempty"`
+	Routers    []string `json:"routers,omitempty"`
+	DNS        []string `json:"dns,omitempty"`
+}
+
+// RADIUSResult keeps response of RADIUS server to test Access-Request.
+type RADIUSResult struct {
+	Response     string `json:"response"`
+	ReplyMessage string `json:"reply_message,omitempty"`
+}
+
+// ARPResult keeps MAC address of host with its vendor, and other MAC addresses replying for the same IP address.
+type ARPResult struct {
+	MAC       string   `json:"mac"`
+	Vendor    string   `json:"vendor,omitempty"`
+	Interface string   `json:"interface,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// TimestampResult keeps one-way delays and clock offset (in seconds) estimated using ICMP timestamp replies,
+// delays are biased by clock offset of host.
+type TimestampResult struct {
+	Forward    float64 `json:"forward"`
+	MaxForward float64 `json:"max_forward"`
+	Reverse    float64 `json:"reverse"`
+	MaxReverse float64 `json:"max_reverse"`
+	Offset     float64 `json:"offset"`
+}
+
+// TWAMPResult keeps delays and jitter (in seconds) and loss of each direction (in percents) measured by TWAMP-Light
+// test packets, one-way delays are biased by clock offset of reflector and directional loss is known only if reflector
+// numbers reflected packets on its own.
+type TWAMPResult struct {
+	RoundTrip     float64  `json:"round_trip"`
+	Forward       float64  `json:"forward"`
+	Reverse       float64  `json:"reverse"`
+	Jitter        float64  `json:"jitter"`
+	ForwardJitter float64  `json:"forward_jitter"`
+	ReverseJitter float64  `json:"reverse_jitter"`
+	ForwardLoss   *float64 `json:"forward_loss,omitempty"`
+	ReverseLoss   *float64 `json:"reverse_loss,omitempty"`
+}
+
+// RateLimitResult keeps loss (in percents) of burst and spaced train of echo requests sent by ratelimit probe,
+// Limited tells if loss depends on send rate.
+type RateLimitResult struct {
+	BurstLoss float64 `json:"burst_loss"`
+	TrainLoss float64 `json:"train_loss"`
+	Limited   bool    `json:"limited"`
+}
+
+// ICMPErrorResult counts ICMP error messages of one type and code, e.g. destination unreachable, sent by host or router
+// in response to probe requests.
+type ICMPErrorResult struct {
+	Type        int    `json:"type"`
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	From        string `json:"from"`
+	Count       int    `json:"count"`
 }
 
 // ProbeResult keep result of go-ping operation.
 type ProbeResult struct {
-	Host    Host
-	Status  string
-	Output  []string
-	Loss    float64
-	AvgTime float64
+	Host            Host
+	Status          string
+	Output          []string
+	Loss            float64
+	Corruption      float64
+	AvgTime         float64
+	TimestampSource string
+	Confirmations   int
+	Annotations     Annotations
+	Services        []ServiceResult
+	ICMPErrors      []ICMPErrorResult
+	SSH             *SSHResult
+	SNMP            *SNMPResult
+	NTP             *NTPResult
+	SQL             *SQLResult
+	GRPC            *GRPCResult
+	DHCP            *DHCPResult
+	RADIUS          *RADIUSResult
+	ARP             *ARPResult
+	Timestamp       *TimestampResult
+	TWAMP           *TWAMPResult
+	RateLimit       *RateLimitResult
+	// Unprobed tells that host couldn't be probed at all, e.g. because of invalid port
+	Unprobed bool
+}
+
+// SourcesConfig defines the way of loading hosts from sources.
+type SourcesConfig struct {
+	Lenient          bool
+	ReportUnresolved bool     `toml:"report_unresolved"`
+	MergePriority    []string `toml:"merge_priority"`
+	File             SourceConfig
+	DB               SourceConfig
+	API              SourceConfig
+}
+
+// ResolverConfig defines DNS resolver used to resolve host names, answers are cached according to their TTLs
+// limited by MinTTL and MaxTTL. If no servers are defined system resolver is used.
+type ResolverConfig struct {
+	Servers []string
+	Timeout Duration
+	MinTTL  Duration     `toml:"min_ttl"`
+	MaxTTL  Duration     `toml:"max_ttl"`
+	Client  HostResolver `toml:"-"`
+}
+
+// EnrichmentConfig defines sources of probed IP addresses annotations, MaxMind format databases
+// (e.g. GeoLite2-City, GeoLite2-ASN) and reverse DNS. Annotations are cached for CacheTTL.
+type EnrichmentConfig struct {
+	ReverseDNS bool `toml:"reverse_dns"`
+	Databases  []string
+	Timeout    Duration
+	CacheTTL   Duration  `toml:"cache_ttl"`
+	Client     Annotator `toml:"-"`
+}
+
+// PacketPacer delays sending packet to ip until it fits configured packets per second limits.
+type PacketPacer interface {
+	Wait(ip string)
+}
+
+// PacingConfig limits packets per second sent by all workers together (Rate) and to each destination subnet
+// (SubnetRate), subnets are defined by SubnetPrefix and SubnetPrefix6 lengths of IPv4 and IPv6 addresses.
+// Hosts of each tests round are started at random offsets spread over Jitter.
+type PacingConfig struct {
+	Rate          int
+	SubnetRate    int `toml:"subnet_rate"`
+	SubnetPrefix  int `toml:"subnet_prefix"`
+	SubnetPrefix6 int `toml:"subnet_prefix6"`
+	Jitter        Duration
+	Client        PacketPacer `toml:"-"`
+}
+
+// ConfirmConfig defines confirmation of failed probes, host with 100% loss is re-queued into workers pool after Delay
+// up to Attempts times and reported as down only if all confirmation probes fail too. Confirmation probes may send
+// Count packets within Timeout or use other ping Protocol (icmp or udp).
+type ConfirmConfig struct {
+	Attempts int
+	Delay    Duration
+	Count    int
+	Timeout  Duration
+	Protocol string
+}
+
+// SweepConfig defines network discovery settings, Rate limits number of probed addresses per second,
+// responsive hosts are also probed using netcat on each of Ports.
+type SweepConfig struct {
+	Rate  int
+	Ports []int
+}
+
+// ScriptStep is one step of netcat send/expect script, Expect is regular expression matched in multi-line mode.
+type ScriptStep struct {
+	Send    string
+	Expect  string
+	Timeout Duration
+	Pattern *regexp.Regexp `toml:"-"`
+}
+
+// ScriptConfig defines netcat send/expect script, run for hosts labeled script=<name> of script
+// or group=<one of Groups>.
+type ScriptConfig struct {
+	Steps  []ScriptStep
+	Groups []string
 }
 
 // GeneralConfig main application configuration.
@@ -49,6 +392,13 @@ type GeneralConfig struct {
 	Workers       int
 	Results       chan ProbeResult
 	Probe         ProbeConfig
+	Sources       SourcesConfig
+	Resolver      ResolverConfig
+	Enrichment    EnrichmentConfig
+	Pacing        PacingConfig
+	Confirm       ConfirmConfig
+	Sweep         SweepConfig
+	Scripts       map[string]ScriptConfig
 	API           APIConfig
 	DB            DBConfig
 }
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -41,21 +42,87 @@ func TestParseHost(t *testing.T) {
 			"",
 			"Can't resolve host: 192.168.1.1/abc",
 		},
+		{
+			"2001:db8::1",
+			"2001:db8::1",
+			"",
+		},
+		{
+			"[2001:db8::1]:22",
+			"2001:db8::1",
+			"",
+		},
+		{
+			"2001:db8::1/64",
+			"2001:db8::1",
+			"",
+		},
+		{
+			"fe80::1%eth0",
+			"fe80::1%eth0",
+			"",
+		},
+		{
+			"[2001:db8::1]:",
+			"",
+			"Host invalid format: [2001:db8::1]:",
+		},
+		{
+			"wp.pl%eth0",
+			"",
+			"Can't resolve host: wp.pl%eth0",
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.Input, func(t *testing.T) {
 			var hosts Hosts
-			_, _, err := hosts.parseHost(tc.Input)
+			ip, _, err := hosts.parseHost(tc.Input)
 
 			if err == nil && tc.ExpectedErrStr != "" ||
 				err != nil && tc.ExpectedErrStr != err.Error() {
 				t.Errorf("got: %v expected: %v", err, tc.ExpectedErrStr)
 			}
+			if ip != tc.Response {
+				t.Errorf("got host: %v expected: %v", ip, tc.Response)
+			}
 		})
 	}
 }
 
+func TestHostWithoutPort(t *testing.T) {
+	var hosts Hosts
+	hosts.SetDeduplication("grpc", nil)
+	if _, _, err := hosts.parseHost("192.168.1.1"); err == nil || err.Error() != "Host without port: 192.168.1.1" {
+		t.Errorf("host without port accepted by mode without default port, got: %v", err)
+	}
+	if _, port, err := hosts.parseHost("192.168.1.1:50051"); err != nil || port != "50051" {
+		t.Errorf("host with port rejected, got: %v, %v", port, err)
+	}
+	if _, port, err := hosts.parseHost("[2001:db8::1]:50051"); err != nil || port != "50051" {
+		t.Errorf("IPv6 host with port rejected, got: %v, %v", port, err)
+	}
+	if _, _, err := hosts.parseHost("2001:db8::1"); err == nil {
+		t.Error("IPv6 host without port accepted by mode without default port")
+	}
+
+	hosts.Init(22)
+	if _, port, err := hosts.parseHost("192.168.1.1"); err != nil || port != "22" {
+		t.Errorf("host without port should get default port, got: %v, %v", port, err)
+	}
+}
+
+func TestTimestampHost(t *testing.T) {
+	var hosts Hosts
+	hosts.SetDeduplication("timestamp", nil)
+	if _, _, err := hosts.parseHost("2001:db8::1"); err == nil {
+		t.Error("IPv6 host accepted by timestamp mode")
+	}
+	if ip, _, err := hosts.parseHost("192.168.1.1"); err != nil || ip != "192.168.1.1" {
+		t.Errorf("IPv4 host rejected by timestamp mode, got: %v, %v", ip, err)
+	}
+}
+
 func TestValidHostsSetGet(t *testing.T) {
 	var hosts Hosts
 	validHosts := []Host{{IP: "192.168.1.1", ID: 0}, {IP: "10.10.0.1", ID: 0}}
@@ -69,7 +136,7 @@ func TestValidHostsSetGet(t *testing.T) {
 	}
 
 	for i, host := range hosts.Get() {
-		if host != validHosts[i] {
+		if !reflect.DeepEqual(host, validHosts[i]) {
 			t.Errorf("hosts.Get returns invalid host id %d", i)
 		}
 	}
@@ -90,3 +157,136 @@ func TestInvalidHostsSetGet(t *testing.T) {
 		t.Error("hosts.Get returns hosts")
 	}
 }
+
+func TestLenientHostsAdd(t *testing.T) {
+	var hosts Hosts
+	hosts.SetLenient(true)
+
+	loader := func(parser HostParser) ([]Host, error) {
+		return []Host{{IP: "192.168.1.1"}}, HostErrors{{Host: Host{ID: 10, IP: "invalid"}, Err: errors.New("error")}}
+	}
+
+	if err := hosts.Add(loader); err != nil {
+		t.Errorf("hosts.Add returns error in lenient mode: %v", err)
+	}
+
+	if len(hosts.Get()) != 1 || hosts.Get()[0].IP != "192.168.1.1" {
+		t.Errorf("hosts.Get returns invalid hosts, got: %v", hosts.Get())
+	}
+
+	invalid := hosts.Invalid()
+	if len(invalid) != 1 || invalid[0].Host.ID != 10 {
+		t.Errorf("hosts.Invalid returns invalid hosts, got: %v", invalid)
+	}
+	if invalid[0].Error() != "host id 10: error" {
+		t.Errorf("unexpected host error, got: %v", invalid[0])
+	}
+
+	hosts.SetLenient(false)
+	hosts.Reset()
+	if err := hosts.Add(loader); err == nil {
+		t.Error("hosts.Add doesn't return error in strict mode")
+	}
+	if hosts.Get() != nil || hosts.Invalid() != nil {
+		t.Error("hosts.Add adds hosts in strict mode")
+	}
+
+	errorLoader := func(parser HostParser) ([]Host, error) {
+		return nil, errors.New("error")
+	}
+	hosts.SetLenient(true)
+	if err := hosts.Add(errorLoader); err == nil {
+		t.Error("hosts.Add doesn't return loader error in lenient mode")
+	}
+}
+
+func TestHostsDeduplication(t *testing.T) {
+	var hosts Hosts
+	hosts.SetDeduplication("netcat", []string{"db", "file"})
+
+	fileLoader := func(parser HostParser) ([]Host, error) {
+		return []Host{
+			{IP: "10.0.0.1", Port: "22", Source: "file", Labels: map[string]string{"site": "waw", "role": "core"}},
+			{IP: "10.0.0.1", Port: "80", Source: "file"},
+		}, nil
+	}
+	dbLoader := func(parser HostParser) ([]Host, error) {
+		return []Host{{ID: 10, IP: "10.0.0.1", Port: "22", Source: "db", Labels: map[string]string{"site": "krk"}}}, nil
+	}
+
+	hosts.Add(fileLoader)
+	hosts.Add(dbLoader)
+
+	if len(hosts.Get()) != 2 || hosts.Duplicates() != 1 {
+		t.Fatalf("expected 2 hosts and 1 duplicate, got: %v, %d", hosts.Get(), hosts.Duplicates())
+	}
+
+	merged := hosts.Get()[0]
+	if merged.ID != 10 || merged.Source != "db" {
+		t.Errorf("merged host should be taken from db, got: %v", merged)
+	}
+	if !reflect.DeepEqual(merged.Labels, map[string]string{"site": "krk", "role": "core"}) {
+		t.Errorf("merged host has invalid labels, got: %v", merged.Labels)
+	}
+
+	hosts.Reset()
+	hosts.SetDeduplication("ping", nil)
+	hosts.Add(fileLoader)
+	if len(hosts.Get()) != 1 || hosts.Duplicates() != 1 {
+		t.Errorf("ping mode should ignore ports, got: %v", hosts.Get())
+	}
+}
+
+type stubResolver map[string]string
+
+func (r stubResolver) Resolve(name string) (string, error) {
+	if ip, ok := r[name]; ok {
+		return ip, nil
+	}
+	return "", errors.New("no such host")
+}
+
+func TestHostsResolve(t *testing.T) {
+	var hosts Hosts
+	hosts.SetResolver(stubResolver{"example.com": "192.0.2.1"})
+
+	hosts.Add(func(parser HostParser) ([]Host, error) {
+		return []Host{{IP: "example.com"}, {IP: "invalid.example.com"}, {IP: "10.0.0.1"}}, nil
+	})
+
+	expected := []Host{
+		{IP: "192.0.2.1", Hostname: "example.com"},
+		{IP: "", Hostname: "invalid.example.com"},
+		{IP: "10.0.0.1"},
+	}
+	if !reflect.DeepEqual(hosts.Get(), expected) {
+		t.Errorf("hosts.Get returns invalid hosts, got: %v", hosts.Get())
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected []string
+	}{
+		{"22", []string{"22"}},
+		{"22,80,443", []string{"22", "80", "443"}},
+		{"8000-8002,22", []string{"8000", "8001", "8002", "22"}},
+		{"0", nil},
+		{"22,abc", nil},
+		{"8100-8000", nil},
+		{"1-65535", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Input, func(t *testing.T) {
+			ports, err := ParsePorts(tc.Input)
+			if tc.Expected == nil && err == nil {
+				t.Errorf("expected error, got: %v", ports)
+			}
+			if !reflect.DeepEqual(ports, tc.Expected) {
+				t.Errorf("expected: %v, got: %v", tc.Expected, ports)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SourceConfig defines settings of one hosts source.
+type SourceConfig struct {
+	Refresh  Duration
+	Snapshot string
+}
+
+// HostsSource loads hosts using HostsLoader not more often than Refresh interval and keeps last successfully loaded
+// list of hosts, used in case of source failure.
+type HostsSource struct {
+	// Name of source used in logs, e.g. file, db, api.
+	Name string
+
+	// Loader loads hosts from source.
+	Loader HostsLoader
+
+	// Refresh specifies minimal time between reloads, zero means reload on each call of Load.
+	Refresh time.Duration
+
+	// Snapshot is optional file name used to keep last successfully loaded list of hosts between restarts.
+	Snapshot string
+
+	// Lenient tells if list of hosts with some invalid entries is accepted as successfully loaded.
+	Lenient bool
+
+	hosts    []Host
+	invalid  HostErrors
+	loaded   bool
+	loadedAt time.Time
+}
+
+// snapshotHost is Host kept in snapshot file, InactiveSince is null if host is active.
+type snapshotHost struct {
+	ID            int               `json:"id"`
+	IP            string            `json:"ip"`
+	Hostname      string            `json:"hostname,omitempty"`
+	Port          string            `json:"port"`
+	InactiveSince *string           `json:"inactive_since"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// NewHostsSource returns HostsSource for given loader and source settings.
+func NewHostsSource(name string, loader HostsLoader, config SourceConfig) *HostsSource {
+	return &HostsSource{
+		Name:     name,
+		Loader:   loader,
+		Refresh:  config.Refresh.Duration,
+		Snapshot: config.Snapshot,
+	}
+}
+
+// Load is HostsLoader returning hosts from source, or cached list of hosts if Refresh interval didn't elapse yet.
+// If source fails last known good list of hosts is returned, read from Snapshot file if nothing was loaded yet.
+func (s *HostsSource) Load(parser HostParser) ([]Host, error) {
+	if s.loaded && time.Since(s.loadedAt) < s.Refresh {
+		return s.cached()
+	}
+
+	hosts, err := s.Loader(parser)
+	_, partial := err.(HostErrors)
+	if err == nil || partial && s.Lenient {
+		hosts = s.withSource(hosts)
+		s.hosts = hosts
+		s.invalid, _ = err.(HostErrors)
+		s.loaded = true
+		s.loadedAt = time.Now()
+
+		if err := s.saveSnapshot(); err != nil {
+			log.Printf("Can't save snapshot of source %s: %v\n", s.Name, err)
+		}
+		return hosts, err
+	}
+	if partial {
+		// invalid entries fail strict loading, so partial list isn't kept as last known good one
+		return s.withSource(hosts), err
+	}
+
+	if !s.loaded {
+		if snapshotErr := s.loadSnapshot(); snapshotErr != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Source %s failed, using last known good list of hosts: %v\n", s.Name, err)
+	return s.cached()
+}
+
+// withSource returns copy of hosts marked as loaded from source, so list owned by loader isn't modified.
+func (s *HostsSource) withSource(hosts []Host) []Host {
+	marked := make([]Host, len(hosts))
+	for i, host := range hosts {
+		host.Source = s.Name
+		marked[i] = host
+	}
+	return marked
+}
+
+func (s *HostsSource) cached() ([]Host, error) {
+	if s.invalid != nil {
+		return s.hosts, s.invalid
+	}
+	return s.hosts, nil
+}
+
+func (s *HostsSource) saveSnapshot() error {
+	if s.Snapshot == "" {
+		return nil
+	}
+
+	records := make([]snapshotHost, len(s.hosts))
+	for i, host := range s.hosts {
+		records[i] = snapshotHost{
+			ID:       host.ID,
+			IP:       host.IP,
+			Hostname: host.Hostname,
+			Port:     host.Port,
+			Labels:   host.Labels,
+		}
+		if host.InactiveSince.Valid {
+			records[i].InactiveSince = &host.InactiveSince.String
+		}
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file first, so failure in the middle won't destroy previous snapshot
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Snapshot), filepath.Base(s.Snapshot))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Snapshot)
+}
+
+func (s *HostsSource) loadSnapshot() error {
+	if s.Snapshot == "" {
+		return os.ErrNotExist
+	}
+
+	data, err := ioutil.ReadFile(s.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	var records []snapshotHost
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	hosts := make([]Host, len(records))
+	for i, record := range records {
+		hosts[i] = Host{
+			ID:       record.ID,
+			IP:       record.IP,
+			Hostname: record.Hostname,
+			Port:     record.Port,
+			Labels:   record.Labels,
+		}
+		if record.InactiveSince != nil {
+			hosts[i].InactiveSince = sql.NullString{String: *record.InactiveSince, Valid: true}
+		}
+	}
+
+	s.hosts = s.withSource(hosts)
+	s.invalid = nil
+	s.loaded = true
+	// snapshot is stale by definition, next Load will try to reach source again
+	s.loadedAt = time.Time{}
+	return nil
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHostsSourceLastKnownGood(t *testing.T) {
+	var fail bool
+	var calls int
+	validHosts := []Host{{ID: 1, IP: "192.168.1.1"}}
+
+	source := NewHostsSource("test", func(parser HostParser) ([]Host, error) {
+		calls++
+		if fail {
+			return nil, errors.New("source unavailable")
+		}
+		return validHosts, nil
+	}, SourceConfig{})
+
+	if _, err := source.Load(nil); err != nil {
+		t.Errorf("source.Load returns error: %v", err)
+	}
+
+	fail = true
+	hosts, err := source.Load(nil)
+	if err != nil {
+		t.Errorf("source.Load returns error instead of cached hosts: %v", err)
+	}
+	expected := Host{ID: 1, IP: "192.168.1.1", Source: "test"}
+	if len(hosts) != 1 || !reflect.DeepEqual(hosts[0], expected) {
+		t.Errorf("source.Load returns invalid cached hosts, got: %v", hosts)
+	}
+	if validHosts[0].Source != "" {
+		t.Errorf("source.Load modifies hosts returned by loader, got: %v", validHosts)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls of loader, got: %d", calls)
+	}
+
+	source.Refresh = time.Hour
+	if _, err := source.Load(nil); err != nil || calls != 2 {
+		t.Errorf("source.Load reloads hosts before refresh interval, calls: %d, error: %v", calls, err)
+	}
+}
+
+func TestHostsSourceSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := SourceConfig{Snapshot: filepath.Join(dir, "hosts.json")}
+	validHosts := []Host{
+		{ID: 1, IP: "192.168.1.1", Port: "22"},
+		{ID: 2, IP: "10.0.0.1", Hostname: "host.example.com", Labels: map[string]string{"site": "waw"}, InactiveSince: sql.NullString{String: "2018-12-07T21:59:58.312Z", Valid: true}},
+	}
+
+	source := NewHostsSource("test", func(parser HostParser) ([]Host, error) {
+		return validHosts, nil
+	}, config)
+	if _, err := source.Load(nil); err != nil {
+		t.Fatalf("source.Load returns error: %v", err)
+	}
+
+	// simulate restart while source is down
+	source = NewHostsSource("test", func(parser HostParser) ([]Host, error) {
+		return nil, errors.New("source unavailable")
+	}, config)
+	hosts, err := source.Load(nil)
+	if err != nil {
+		t.Fatalf("source.Load doesn't use snapshot, got error: %v", err)
+	}
+	for i := range validHosts {
+		expected := validHosts[i]
+		expected.Source = "test"
+		if i >= len(hosts) || !reflect.DeepEqual(hosts[i], expected) {
+			t.Errorf("snapshot returns invalid host id %d, got: %v", i, hosts)
+		}
+	}
+
+	source = NewHostsSource("test", func(parser HostParser) ([]Host, error) {
+		return nil, errors.New("source unavailable")
+	}, SourceConfig{})
+	if _, err := source.Load(nil); err == nil {
+		t.Error("source.Load doesn't return error without cache and snapshot")
+	}
+}
+
+func TestHostsSourceStrictPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := SourceConfig{Snapshot: filepath.Join(dir, "hosts.json")}
+	partial := func(parser HostParser) ([]Host, error) {
+		return []Host{{IP: "192.168.1.1"}}, HostErrors{{Host: Host{IP: "invalid"}, Err: errors.New("invalid host")}}
+	}
+
+	source := NewHostsSource("test", partial, config)
+	if _, err := source.Load(nil); err == nil {
+		t.Error("source.Load doesn't return invalid hosts")
+	}
+	if _, err := os.Stat(config.Snapshot); !os.IsNotExist(err) {
+		t.Errorf("partial list of hosts saved as snapshot in strict mode, got: %v", err)
+	}
+	if source.loaded {
+		t.Error("partial list of hosts kept as last known good in strict mode")
+	}
+
+	source.Lenient = true
+	if hosts, err := source.Load(nil); len(hosts) != 1 || err == nil {
+		t.Errorf("lenient source.Load should return valid and invalid hosts, got: %v, %v", hosts, err)
+	}
+	if _, err := os.Stat(config.Snapshot); err != nil {
+		t.Errorf("partial list of hosts accepted in lenient mode not saved as snapshot, got: %v", err)
+	}
+}
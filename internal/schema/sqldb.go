@@ -11,6 +11,8 @@ type DBConfig struct {
 
 // DBQueries defines list of database queries.
 type DBQueries struct {
-	GetDevices   string `toml:"get_devices"`
-	UpdateDevice string `toml:"update_device"`
+	GetDevices              string `toml:"get_devices"`
+	UpdateDevice            string `toml:"update_device"`
+	UpdateDeviceAnnotations string `toml:"update_device_annotations"`
+	InsertDevice            string `toml:"insert_device"`
 }
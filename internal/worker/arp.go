@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/arpprobe"
+)
+
+// ARP worker iterates over schema.Host tasks, resolving MAC address of each of them using ARP or NDP and push results
+// into config.Results channel, many MAC addresses replying for the same IP address are reported as WARNING status.
+func ARP(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := arpprobe.NewARPProbe(device.IP)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *arpprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("Address resolution of %s failed, %v!\n", stats.Addr, stats.Error))
+				return
+			}
+
+			result.ARP = &schema.ARPResult{
+				MAC:       stats.MAC.String(),
+				Vendor:    arpprobe.Vendor(config.Probe.ARP.Vendors, stats.MAC),
+				Interface: stats.Interface,
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s arp statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d requests transmitted, %d replies received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("round-trip min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			line += fmt.Sprintf("%s is at %s on %s", stats.Addr, result.ARP.MAC, stats.Interface)
+			if result.ARP.Vendor != "" {
+				line += fmt.Sprintf(" (%s)", result.ARP.Vendor)
+			}
+			line += "\n"
+
+			if len(stats.Conflicts) > 0 {
+				for _, mac := range stats.Conflicts {
+					result.ARP.Conflicts = append(result.ARP.Conflicts, mac.String())
+				}
+				result.Status = schema.StatusWarning
+				line += fmt.Sprintf("IP conflict, %s is claimed also by %s!\n", stats.Addr, strings.Join(result.ARP.Conflicts, ", "))
+			}
+			result.Output = append(result.Output, line)
+		}
+
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
@@ -0,0 +1,236 @@
+package arpprobe
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+// collectWindow is how long other replies to request are collected after the first one.
+const collectWindow = 100 * time.Millisecond
+
+// ARPProbe resolves MAC address of directly connected host using ARP request (IPv4) or neighbour solicitation (IPv6)
+// sent on interface of host network, and measures reply time.
+type ARPProbe struct {
+	ipaddr *net.IPAddr
+	ip     string
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	rtts          []time.Duration
+	macs          []net.HardwareAddr
+	ifname        string
+	err           error
+
+	// OnFinish is called when ARPProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Statistics represent the stats of a ARPProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Interface is name of interface requests were sent on.
+	Interface string
+
+	// RequestsSent is the number of requests sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of replies received.
+	ResponsesRecv int
+
+	// Loss is the percentage of requests without reply.
+	Loss float64
+
+	// MinRtt is the minimum reply time.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum reply time.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average reply time.
+	AvgRtt time.Duration
+
+	// MAC is hardware address of the first reply.
+	MAC net.HardwareAddr
+
+	// Conflicts are other hardware addresses replying for the same IP address.
+	Conflicts []net.HardwareAddr
+
+	// Error specifies the last request error.
+	Error error
+}
+
+// NewARPProbe returns a new ARPProbe struct pointer, IPv6 link-local host should specify zone, e.g. "fe80::1%eth0".
+func NewARPProbe(host string) (*ARPProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ARPProbe{
+		ipaddr:   ip,
+		ip:       ip.String(),
+		Count:    1,
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *ARPProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *ARPProbe) run() {
+	ifi, src, err := interfaceFor(p.ipaddr)
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.ifname = ifi.Name
+
+	var n neighbour
+	if p.ipaddr.IP.To4() != nil {
+		n, err = newARP(ifi, src)
+	} else {
+		n, err = newNDP(ifi)
+	}
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer n.Close()
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		p.request(n)
+	}
+}
+
+// request sends one request, reply time is measured to the first reply, but replies are collected
+// until timeout or collectWindow after the first one, so all hosts claiming the address are noticed.
+func (p *ARPProbe) request(n neighbour) {
+	p.requestsSent++
+	start := time.Now()
+	if err := n.Request(p.ipaddr.IP); err != nil {
+		p.err = err
+		return
+	}
+	deadline := start.Add(p.Timeout)
+	mac, err := n.Reply(p.ipaddr.IP, deadline)
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.rtts = append(p.rtts, time.Since(start))
+	p.responsesRecv++
+	p.macs = append(p.macs, mac)
+
+	if window := time.Now().Add(collectWindow); window.Before(deadline) {
+		deadline = window
+	}
+	for {
+		mac, err := n.Reply(p.ipaddr.IP, deadline)
+		if err != nil {
+			return
+		}
+		p.macs = append(p.macs, mac)
+	}
+}
+
+// neighbour sends address resolution requests and waits for replies.
+type neighbour interface {
+	Request(ip net.IP) error
+	// Reply returns hardware address of the next reply for ip received before deadline.
+	Reply(ip net.IP, deadline time.Time) (net.HardwareAddr, error)
+	Close() error
+}
+
+// interfaceFor returns interface with network containing ip and its address from this network.
+func interfaceFor(ipaddr *net.IPAddr) (*net.Interface, net.IP, error) {
+	if ipaddr.Zone != "" {
+		ifi, err := net.InterfaceByName(ipaddr.Zone)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ifi, nil, nil
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range interfaces {
+		ifi := &interfaces[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 || len(ifi.HardwareAddr) == 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.Contains(ipaddr.IP) {
+				return ifi, ipnet.IP, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("host %s is not directly connected", ipaddr)
+}
+
+// Statistics returns the statistics of the ARPProbe.
+func (p *ARPProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Interface:     p.ifname,
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Error:         p.err,
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	for _, mac := range p.macs {
+		if s.MAC == nil {
+			s.MAC = mac
+			continue
+		}
+		conflict := mac.String() != s.MAC.String()
+		for _, c := range s.Conflicts {
+			if mac.String() == c.String() {
+				conflict = false
+			}
+		}
+		if conflict {
+			s.Conflicts = append(s.Conflicts, mac)
+		}
+	}
+
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(p.rtts)
+	return &s
+}
@@ -0,0 +1,166 @@
+package arpprobe
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestARPPacket(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	request := arpRequestPacket(mac, net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1"))
+
+	// turn request into reply of 10.0.0.1
+	reply := make([]byte, len(request))
+	copy(reply, request)
+	reply[7] = arpReply
+	copy(reply[8:14], []byte{0x00, 0x00, 0x0c, 0x01, 0x02, 0x03})
+	copy(reply[14:18], net.ParseIP("10.0.0.1").To4())
+
+	if _, ok := parseARPReply(request, net.ParseIP("10.0.0.1")); ok {
+		t.Error("request parsed as reply")
+	}
+	if got, ok := parseARPReply(reply, net.ParseIP("10.0.0.1")); !ok || got.String() != "00:00:0c:01:02:03" {
+		t.Errorf("invalid reply MAC, got: %v", got)
+	}
+	if _, ok := parseARPReply(reply, net.ParseIP("10.0.0.3")); ok {
+		t.Error("reply of other host accepted")
+	}
+}
+
+func TestNDPBody(t *testing.T) {
+	target := net.ParseIP("2001:db8::1234:5678")
+	if node := solicitedNode(target); node.String() != "ff02::1:ff34:5678" {
+		t.Errorf("invalid solicited-node address, got: %v", node)
+	}
+
+	mac, _ := net.ParseMAC("00:00:0c:01:02:03")
+	body := solicitationBody(mac, target)
+	body[len(body)-8] = optTargetLinkLayer
+	if got, err := parseAdvertisementBody(body, target); err != nil || got.String() != mac.String() {
+		t.Errorf("invalid advertisement MAC, got: %v %v", got, err)
+	}
+	if _, err := parseAdvertisementBody(body, net.ParseIP("2001:db8::1")); err != errNotMatching {
+		t.Errorf("advertisement of other host accepted, got: %v", err)
+	}
+}
+
+func TestNDPMessages(t *testing.T) {
+	target := net.ParseIP("fe80::200:cff:fe01:203")
+	mac, _ := net.ParseMAC("00:00:0c:01:02:03")
+
+	solicitation, err := solicitationMessage(mac, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solicitation[0] != byte(ipv6.ICMPTypeNeighborSolicitation) || !net.IP(solicitation[8:24]).Equal(target) {
+		t.Errorf("invalid solicitation of %s, got: %x", target, solicitation)
+	}
+	if solicitation[24] != optSourceLinkLayer || net.HardwareAddr(solicitation[26:32]).String() != mac.String() {
+		t.Errorf("solicitation without source link-layer address, got: %x", solicitation[24:])
+	}
+
+	// advertisement with other option before target link-layer address
+	body := make([]byte, 4, 40)
+	body = append(body, target...)
+	body = append(body, 14, 1, 1, 2, 3, 4, 5, 6)
+	body = append(body, optTargetLinkLayer, 1)
+	body = append(body, mac...)
+	advertisement, err := (&icmp.Message{Type: ipv6.ICMPTypeNeighborAdvertisement, Body: &icmp.RawBody{Data: body}}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parseAdvertisement(advertisement, target); err != nil || got.String() != mac.String() {
+		t.Errorf("invalid advertisement MAC, got: %v %v", got, err)
+	}
+	if _, err := parseAdvertisement(solicitation, target); err != errNotMatching {
+		t.Errorf("solicitation accepted as advertisement, got: %v", err)
+	}
+	if _, err := parseAdvertisement(advertisement[:4+4+net.IPv6len], target); err == nil || err == errNotMatching {
+		t.Errorf("advertisement without link-layer address accepted, got: %v", err)
+	}
+}
+
+func TestLoadVendors(t *testing.T) {
+	file, err := ioutil.TempFile("", "oui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("OUI/MA-L                                                      Organization\n" +
+		"00-00-0C   (hex)\t\tCisco Systems, Inc\n" +
+		"00000C     (base 16)\t\tCisco Systems, Inc\n" +
+		"\t\t\t\t170 WEST TASMAN DRIVE\n" +
+		"# wireshark manuf\n" +
+		"00:1B:21\tIntel\tIntel Corporate\n" +
+		"00:55:DA:00/28\tShinkoTe\tShinko Technos co.,ltd.\n")
+	file.Close()
+
+	vendors, err := LoadVendors(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vendors) != 2 {
+		t.Errorf("expected 2 vendors, got: %v", vendors)
+	}
+
+	cases := map[string]string{
+		"00:00:0c:01:02:03": "Cisco Systems, Inc",
+		"00:1b:21:aa:bb:cc": "Intel Corporate",
+		"02:00:00:00:00:01": "",
+	}
+	for address, vendor := range cases {
+		mac, _ := net.ParseMAC(address)
+		if got := Vendor(vendors, mac); got != vendor {
+			t.Errorf("vendor of %s, got: %q, expected: %q", address, got, vendor)
+		}
+	}
+}
+
+// replies is neighbour returning queued replies, then timing out.
+type replies []net.HardwareAddr
+
+func (r *replies) Request(ip net.IP) error {
+	return nil
+}
+
+func (r *replies) Reply(ip net.IP, deadline time.Time) (net.HardwareAddr, error) {
+	if len(*r) == 0 {
+		return nil, errTimeout
+	}
+	mac := (*r)[0]
+	*r = (*r)[1:]
+	return mac, nil
+}
+
+func (r *replies) Close() error {
+	return nil
+}
+
+func TestCollectReplies(t *testing.T) {
+	probe, err := NewARPProbe("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := net.ParseMAC("00:00:0c:01:02:03")
+	second, _ := net.ParseMAC("00:00:0c:04:05:06")
+	n := &replies{first, first, second}
+	probe.request(n)
+
+	s := probe.Statistics()
+	if s.ResponsesRecv != 1 || len(probe.rtts) != 1 {
+		t.Errorf("expected one response with one reply time, got: %d responses, %d reply times", s.ResponsesRecv, len(probe.rtts))
+	}
+	if s.MAC.String() != first.String() {
+		t.Errorf("expected MAC of first reply %s, got: %s", first, s.MAC)
+	}
+	if len(s.Conflicts) != 1 || s.Conflicts[0].String() != second.String() {
+		t.Errorf("expected conflict with %s, got: %v", second, s.Conflicts)
+	}
+}
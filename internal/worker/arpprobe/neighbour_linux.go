@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package arpprobe
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// arp sends ARP requests using packet socket bound to interface.
+type arp struct {
+	fd  int
+	ifi *net.Interface
+	src net.IP
+}
+
+func newARP(ifi *net.Interface, src net.IP) (neighbour, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(etherTypeARP)))
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(etherTypeARP), Ifindex: ifi.Index}); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &arp{fd: fd, ifi: ifi, src: src}, nil
+}
+
+func (a *arp) Request(ip net.IP) error {
+	broadcast := unix.SockaddrLinklayer{Protocol: htons(etherTypeARP), Ifindex: a.ifi.Index, Halen: 6}
+	for i := 0; i < 6; i++ {
+		broadcast.Addr[i] = 0xff
+	}
+	return unix.Sendto(a.fd, arpRequestPacket(a.ifi.HardwareAddr, a.src, ip), 0, &broadcast)
+}
+
+func (a *arp) Reply(ip net.IP, deadline time.Time) (net.HardwareAddr, error) {
+	buf := make([]byte, 128)
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return nil, errTimeout
+		}
+		tv := unix.NsecToTimeval(timeout.Nanoseconds())
+		if err := unix.SetsockoptTimeval(a.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return nil, err
+		}
+
+		n, _, err := unix.Recvfrom(a.fd, buf, 0)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mac, ok := parseARPReply(buf[:n], ip); ok {
+			return mac, nil
+		}
+	}
+}
+
+func (a *arp) Close() error {
+	return unix.Close(a.fd)
+}
+
+// ndp sends neighbour solicitations using raw ICMPv6 socket.
+type ndp struct {
+	conn *icmp.PacketConn
+	ifi  *net.Interface
+}
+
+func newNDP(ifi *net.Interface) (neighbour, error) {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, err
+	}
+
+	// neighbour discovery messages are accepted only with hop limit 255
+	pc := conn.IPv6PacketConn()
+	if err := pc.SetMulticastInterface(ifi); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	pc.SetMulticastHopLimit(255)
+	pc.SetHopLimit(255)
+
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	pc.SetICMPFilter(&filter)
+
+	return &ndp{conn: conn, ifi: ifi}, nil
+}
+
+func (n *ndp) Request(ip net.IP) error {
+	packet, err := solicitationMessage(n.ifi.HardwareAddr, ip)
+	if err != nil {
+		return err
+	}
+	_, err = n.conn.WriteTo(packet, &net.IPAddr{IP: solicitedNode(ip), Zone: n.ifi.Name})
+	return err
+}
+
+func (n *ndp) Reply(ip net.IP, deadline time.Time) (net.HardwareAddr, error) {
+	n.conn.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+	for {
+		size, _, err := n.conn.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		mac, err := parseAdvertisement(buf[:size], ip)
+		if err == errNotMatching {
+			continue
+		}
+		return mac, err
+	}
+}
+
+func (n *ndp) Close() error {
+	return n.conn.Close()
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package arpprobe
+
+import (
+	"errors"
+	"net"
+)
+
+var errUnsupported = errors.New("ARP and NDP probes are supported only on Linux")
+
+func newARP(ifi *net.Interface, src net.IP) (neighbour, error) {
+	return nil, errUnsupported
+}
+
+func newNDP(ifi *net.Interface) (neighbour, error) {
+	return nil, errUnsupported
+}
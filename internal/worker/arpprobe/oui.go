@@ -0,0 +1,55 @@
+package arpprobe
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+)
+
+// LoadVendors loads OUI vendors database, both IEEE oui.txt ("00-00-0C   (hex)		Cisco Systems, Inc")
+// and Wireshark manuf ("00:00:0C	Cisco	Cisco Systems, Inc") formats are accepted.
+func LoadVendors(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vendors := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		oui := strings.ToUpper(strings.NewReplacer("-", "", ":", "", ".", "").Replace(fields[0]))
+		if len(oui) != 6 || len(fields) < 2 {
+			// skip descriptions and longer MA-M/MA-S prefixes
+			continue
+		}
+
+		var vendor string
+		if i := strings.Index(line, "(hex)"); i >= 0 {
+			vendor = strings.TrimSpace(line[i+len("(hex)"):])
+		} else if parts := strings.Split(line, "\t"); len(parts) > 2 {
+			vendor = strings.TrimSpace(parts[len(parts)-1])
+		} else {
+			vendor = strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		}
+		if vendor != "" {
+			vendors[oui] = vendor
+		}
+	}
+	return vendors, scanner.Err()
+}
+
+// Vendor returns vendor of hardware address using vendors database.
+func Vendor(vendors map[string]string, mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	return vendors[strings.ToUpper(strings.ReplaceAll(mac[:3].String(), ":", ""))]
+}
@@ -0,0 +1,115 @@
+package arpprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// ARP packet fields for Ethernet and IPv4, RFC 826.
+const (
+	arpSize      = 28
+	arpRequest   = 1
+	arpReply     = 2
+	etherTypeARP = 0x0806
+	etherTypeIP  = 0x0800
+)
+
+var (
+	// errNotMatching is returned for message not related to probed address.
+	errNotMatching = errors.New("not matching")
+
+	errTimeout = errors.New("request timed out")
+)
+
+// NDP option carrying link-layer address, RFC 4861.
+const (
+	optSourceLinkLayer = 1
+	optTargetLinkLayer = 2
+)
+
+// arpRequestPacket builds ARP request asking about dst address.
+func arpRequestPacket(mac net.HardwareAddr, src, dst net.IP) []byte {
+	packet := make([]byte, arpSize)
+	binary.BigEndian.PutUint16(packet[0:2], 1) // ethernet
+	binary.BigEndian.PutUint16(packet[2:4], etherTypeIP)
+	packet[4] = 6
+	packet[5] = net.IPv4len
+	binary.BigEndian.PutUint16(packet[6:8], arpRequest)
+	copy(packet[8:14], mac)
+	copy(packet[14:18], src.To4())
+	copy(packet[24:28], dst.To4())
+	return packet
+}
+
+// parseARPReply returns sender hardware address of ARP reply sent by ip.
+func parseARPReply(packet []byte, ip net.IP) (net.HardwareAddr, bool) {
+	if len(packet) < arpSize || binary.BigEndian.Uint16(packet[6:8]) != arpReply ||
+		packet[4] != 6 || !net.IP(packet[14:18]).Equal(ip) {
+		return nil, false
+	}
+	return net.HardwareAddr(append([]byte(nil), packet[8:14]...)), true
+}
+
+// solicitedNode returns solicited-node multicast address of ip.
+func solicitedNode(ip net.IP) net.IP {
+	node := net.ParseIP("ff02::1:ff00:0")
+	copy(node[13:], ip.To16()[13:])
+	return node
+}
+
+// solicitationBody builds body of neighbour solicitation message asking about target address.
+func solicitationBody(mac net.HardwareAddr, target net.IP) []byte {
+	body := make([]byte, 4, 4+net.IPv6len+8)
+	body = append(body, target.To16()...)
+	body = append(body, optSourceLinkLayer, 1)
+	return append(body, mac...)
+}
+
+// parseAdvertisementBody returns target link-layer address of neighbour advertisement of target address.
+func parseAdvertisementBody(body []byte, target net.IP) (net.HardwareAddr, error) {
+	if len(body) < 4+net.IPv6len || !net.IP(body[4:4+net.IPv6len]).Equal(target) {
+		return nil, errNotMatching
+	}
+
+	options := body[4+net.IPv6len:]
+	for len(options) >= 8 {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			break
+		}
+		if options[0] == optTargetLinkLayer {
+			return net.HardwareAddr(append([]byte(nil), options[2:8]...)), nil
+		}
+		options = options[length:]
+	}
+	return nil, fmt.Errorf("advertisement of %s without link-layer address", target)
+}
+
+// solicitationMessage builds neighbour solicitation message asking about target address, checksum of ICMPv6
+// is left to kernel.
+func solicitationMessage(mac net.HardwareAddr, target net.IP) ([]byte, error) {
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborSolicitation,
+		Body: &icmp.RawBody{Data: solicitationBody(mac, target)},
+	}
+	return msg.Marshal(nil)
+}
+
+// parseAdvertisement returns target link-layer address of neighbour advertisement message of target address,
+// errNotMatching is returned for other messages.
+func parseAdvertisement(packet []byte, target net.IP) (net.HardwareAddr, error) {
+	msg, err := icmp.ParseMessage(ipv6.ICMPTypeNeighborAdvertisement.Protocol(), packet)
+	if err != nil || msg.Type != ipv6.ICMPTypeNeighborAdvertisement {
+		return nil, errNotMatching
+	}
+	body, ok := msg.Body.(*icmp.RawBody)
+	if !ok {
+		return nil, errNotMatching
+	}
+	return parseAdvertisementBody(body.Data, target)
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+)
+
+// Confirmer re-queues hosts failed by probe into workers pool, so they are reported as down only if confirmation
+// probes fail too.
+type Confirmer struct {
+	config schema.ConfirmConfig
+	jobs   chan<- schema.Host
+
+	// pending counts queued hosts till their final results, including scheduled confirmations
+	pending sync.WaitGroup
+}
+
+// NewConfirmer returns Confirmer configured by ConfirmConfig re-queueing hosts into jobs.
+func NewConfirmer(config schema.ConfirmConfig, jobs chan<- schema.Host) *Confirmer {
+	return &Confirmer{config: config, jobs: jobs}
+}
+
+// Push queues host into workers pool.
+func (c *Confirmer) Push(host schema.Host) {
+	if c.config.Attempts > 0 {
+		c.pending.Add(1)
+	}
+	c.jobs <- host
+}
+
+// Wait blocks until all pushed hosts and their confirmations are probed, so jobs may be closed.
+func (c *Confirmer) Wait() {
+	c.pending.Wait()
+}
+
+// Worker wraps probe worker w, failed results are held back and host is re-queued for confirmation,
+// w is returned as is if confirmations are disabled.
+func (c *Confirmer) Worker(w schema.Worker) schema.Worker {
+	if c.config.Attempts == 0 {
+		return w
+	}
+
+	return func(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		for device := range jobs {
+			c.probe(w, id, config, device)
+			c.pending.Done()
+		}
+	}
+}
+
+// probe runs worker w for one host and passes its results to confirm.
+func (c *Confirmer) probe(w schema.Worker, id int, config schema.GeneralConfig, device schema.Host) {
+	probeConfig := config
+	if device.Confirmations > 0 {
+		if c.config.Count > 0 {
+			probeConfig.Probe.Count = c.config.Count
+		}
+		if c.config.Timeout.Duration > 0 {
+			probeConfig.Probe.Timeout = c.config.Timeout
+		}
+		switch c.config.Protocol {
+		case "icmp":
+			probeConfig.Probe.Privileged = true
+		case "udp":
+			probeConfig.Probe.Privileged = false
+		}
+	}
+
+	job := make(chan schema.Host, 1)
+	job <- device
+	close(job)
+	results := make(chan schema.ProbeResult)
+	probeConfig.Results = results
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		w(id, probeConfig, job, &wg)
+		close(results)
+	}()
+
+	for result := range results {
+		c.confirm(config, result)
+	}
+}
+
+// confirm re-queues host of failed result after delay, unless all confirmation attempts are done,
+// other results are pushed into config.Results. Hosts which couldn't be resolved or probed at all
+// aren't confirmed, probing them again can't change result.
+func (c *Confirmer) confirm(config schema.GeneralConfig, result schema.ProbeResult) {
+	host := result.Host
+	lost := result.Loss == 100 && !result.Unprobed && result.Status != schema.StatusResolveError
+	if lost && host.Confirmations < c.config.Attempts {
+		host.Confirmations++
+		c.pending.Add(1)
+		time.AfterFunc(c.config.Delay.Duration, func() {
+			c.jobs <- host
+		})
+		return
+	}
+
+	if host.Confirmations > 0 {
+		result.Confirmations = host.Confirmations
+		if result.Loss == 100 {
+			result.Output = append(result.Output, fmt.Sprintf("Host %s confirmed down by %d confirmation probes\n", host.IP, host.Confirmations))
+		} else {
+			result.Output = append(result.Output, fmt.Sprintf("Host %s failed, recovered on confirmation probe %d\n", host.IP, host.Confirmations))
+		}
+	}
+	config.Results <- result
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/dhcpprobe"
+)
+
+func ipStrings(ips []net.IP) []string {
+	var list []string
+	for _, ip := range ips {
+		list = append(list, ip.String())
+	}
+	return list
+}
+
+// DHCP worker iterates over schema.Host tasks, sending DHCPDISCOVER to each of them and waiting for DHCPOFFER,
+// results are pushed into config.Results channel. Offered leases are never requested.
+func DHCP(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := dhcpprobe.NewDHCPProbe(device.IP, device.Port)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *dhcpprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("DHCP server %s:%s didn't offer lease, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			offer := stats.Offer
+			result.DHCP = &schema.DHCPResult{
+				OfferedIP: offer.Address.String(),
+				LeaseTime: offer.LeaseTime.Seconds(),
+				Routers:   ipStrings(offer.Routers),
+				DNS:       ipStrings(offer.DNS),
+			}
+			if offer.ServerID != nil {
+				result.DHCP.ServerID = offer.ServerID.String()
+			}
+			if offer.SubnetMask != nil {
+				result.DHCP.SubnetMask = offer.SubnetMask.String()
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s dhcp statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d discovers transmitted, %d offers received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("time to offer min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			line += fmt.Sprintf("offered %s/%s by %s, lease=%v, routers=%s, dns=%s\n",
+				result.DHCP.OfferedIP, result.DHCP.SubnetMask, result.DHCP.ServerID, offer.LeaseTime,
+				strings.Join(result.DHCP.Routers, ","), strings.Join(result.DHCP.DNS, ","))
+			result.Output = append(result.Output, line)
+		}
+
+		if config.Probe.DHCP.HardwareAddr != nil {
+			probe.ClientMAC = config.Probe.DHCP.HardwareAddr
+		}
+		probe.Giaddr = config.Probe.DHCP.RelayAddr
+		probe.LocalPort = config.Probe.DHCP.LocalPort
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
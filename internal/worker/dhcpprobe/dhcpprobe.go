@@ -0,0 +1,350 @@
+package dhcpprobe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+// DHCP message types and options used by probe, RFC 2131 and RFC 2132.
+const (
+	opRequest = 1
+	opReply   = 2
+
+	msgDiscover = 1
+	msgOffer    = 2
+
+	optSubnetMask   = 1
+	optRouter       = 3
+	optDNS          = 6
+	optLeaseTime    = 51
+	optMessageType  = 53
+	optServerID     = 54
+	optParamRequest = 55
+	optClientID     = 61
+	optEnd          = 255
+	optPad          = 0
+
+	headerSize = 236
+	minSize    = 300
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// MaxClientMACLength is size of chaddr field, longer client hardware addresses don't fit in DISCOVER.
+const MaxClientMACLength = 16
+
+// DHCPProbe sends DHCPDISCOVER to server or relay and waits for DHCPOFFER, DHCPREQUEST is never sent so no lease is taken.
+type DHCPProbe struct {
+	ip   string
+	port string
+
+	// ClientMAC is hardware address sent in DISCOVER, at most MaxClientMACLength bytes long,
+	// default is random locally administered address.
+	ClientMAC net.HardwareAddr
+
+	// Giaddr is relay agent address sent in DISCOVER, server sends OFFER to this address.
+	Giaddr net.IP
+
+	// LocalPort is port OFFER is received on, default is 67 if Giaddr is set (as relay) or 68 otherwise (as client).
+	LocalPort int
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	rtts          []time.Duration
+	offer         Offer
+	err           error
+
+	// OnFinish is called when DHCPProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Offer is address and lease options offered by server.
+type Offer struct {
+	// Address is offered client address (yiaddr).
+	Address net.IP
+
+	// ServerID is address of server which sent offer.
+	ServerID net.IP
+
+	// LeaseTime is offered lease time.
+	LeaseTime time.Duration
+
+	// SubnetMask is offered subnet mask.
+	SubnetMask net.IP
+
+	// Routers are offered default gateways.
+	Routers []net.IP
+
+	// DNS are offered name servers.
+	DNS []net.IP
+}
+
+// Statistics represent the stats of a DHCPProbe
+type Statistics struct {
+	// Addr is the string address of the server being probed.
+	Addr string
+
+	// Port is DHCP server port.
+	Port string
+
+	// RequestsSent is the number of DISCOVER messages sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of OFFER messages received.
+	ResponsesRecv int
+
+	// Loss is the percentage of DISCOVER messages without offer.
+	Loss float64
+
+	// MinRtt is the minimum time to offer.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum time to offer.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average time to offer.
+	AvgRtt time.Duration
+
+	// Offer is the last received offer.
+	Offer Offer
+
+	// Error specifies the last request error.
+	Error error
+}
+
+// NewDHCPProbe returns a new DHCPProbe struct pointer.
+func NewDHCPProbe(host, port string) (*DHCPProbe, error) {
+	ip, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	mac := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(mac); err != nil {
+		return nil, err
+	}
+	// locally administered unicast address
+	mac[0] = mac[0]&0xfc | 0x02
+
+	return &DHCPProbe{
+		ip:        ip.String(),
+		port:      port,
+		ClientMAC: mac,
+		Count:     1,
+		Interval:  time.Second,
+		Timeout:   time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *DHCPProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *DHCPProbe) run() {
+	localPort := p.LocalPort
+	if localPort == 0 {
+		localPort = 68
+		if p.Giaddr != nil {
+			localPort = 67
+		}
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: localPort})
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+
+	server, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(p.ip, p.port))
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		p.requestsSent++
+		offer, rtt, err := p.discover(conn, server)
+		if err != nil {
+			p.err = err
+			continue
+		}
+		p.rtts = append(p.rtts, rtt)
+		p.responsesRecv++
+		p.offer = offer
+	}
+}
+
+// discover sends one DISCOVER and waits for matching OFFER.
+func (p *DHCPProbe) discover(conn *net.UDPConn, server *net.UDPAddr) (Offer, time.Duration, error) {
+	xid := make([]byte, 4)
+	if _, err := rand.Read(xid); err != nil {
+		return Offer{}, 0, err
+	}
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(p.Timeout))
+	if _, err := conn.WriteToUDP(p.discoverPacket(xid), server); err != nil {
+		return Offer{}, 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return Offer{}, 0, err
+		}
+		rtt := time.Since(start)
+
+		offer, ok := p.parseOffer(buf[:n], xid)
+		if !ok {
+			// reply to other client or other message
+			continue
+		}
+		return offer, rtt, nil
+	}
+}
+
+// discoverPacket builds DHCPDISCOVER message with transaction ID xid.
+func (p *DHCPProbe) discoverPacket(xid []byte) []byte {
+	packet := make([]byte, headerSize, minSize)
+	packet[0] = opRequest
+	packet[1] = 1 // ethernet
+	packet[2] = byte(len(p.ClientMAC))
+	copy(packet[4:8], xid)
+	if p.Giaddr != nil {
+		packet[3] = 1 // hops
+		copy(packet[24:28], p.Giaddr.To4())
+	} else {
+		// ask server to broadcast reply, client has no address yet
+		binary.BigEndian.PutUint16(packet[10:12], 0x8000)
+	}
+	copy(packet[28:44], p.ClientMAC)
+
+	packet = append(packet, magicCookie...)
+	packet = append(packet, optMessageType, 1, msgDiscover)
+	packet = append(packet, optClientID, byte(len(p.ClientMAC)+1), 1)
+	packet = append(packet, p.ClientMAC...)
+	packet = append(packet, optParamRequest, 4, optSubnetMask, optRouter, optDNS, optLeaseTime)
+	packet = append(packet, optEnd)
+	for len(packet) < minSize {
+		packet = append(packet, optPad)
+	}
+	return packet
+}
+
+// parseOffer parses DHCPOFFER message matching transaction ID xid and client hardware address.
+func (p *DHCPProbe) parseOffer(packet, xid []byte) (Offer, bool) {
+	var offer Offer
+	if len(packet) < headerSize+len(magicCookie) || packet[0] != opReply ||
+		!bytes.Equal(packet[4:8], xid) || !bytes.Equal(packet[28:28+len(p.ClientMAC)], p.ClientMAC) ||
+		!bytes.Equal(packet[headerSize:headerSize+4], magicCookie) {
+		return offer, false
+	}
+	offer.Address = net.IP(append([]byte(nil), packet[16:20]...))
+
+	var msgType byte
+	options := packet[headerSize+4:]
+	for len(options) > 0 {
+		code := options[0]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			options = options[1:]
+			continue
+		}
+		if len(options) < 2 || len(options) < 2+int(options[1]) {
+			return offer, false
+		}
+		value := options[2 : 2+int(options[1])]
+		options = options[2+int(options[1]):]
+
+		switch code {
+		case optMessageType:
+			if len(value) == 1 {
+				msgType = value[0]
+			}
+		case optServerID:
+			offer.ServerID = ip(value)
+		case optSubnetMask:
+			offer.SubnetMask = ip(value)
+		case optLeaseTime:
+			if len(value) == 4 {
+				offer.LeaseTime = time.Duration(binary.BigEndian.Uint32(value)) * time.Second
+			}
+		case optRouter:
+			offer.Routers = ips(value)
+		case optDNS:
+			offer.DNS = ips(value)
+		}
+	}
+	return offer, msgType == msgOffer
+}
+
+func ip(value []byte) net.IP {
+	if len(value) != net.IPv4len {
+		return nil
+	}
+	return net.IP(append([]byte(nil), value...))
+}
+
+func ips(value []byte) []net.IP {
+	var list []net.IP
+	for len(value) >= net.IPv4len {
+		list = append(list, ip(value[:net.IPv4len]))
+		value = value[net.IPv4len:]
+	}
+	return list
+}
+
+// Statistics returns the statistics of the DHCPProbe.
+func (p *DHCPProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          p.port,
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Offer:         p.offer,
+		Error:         p.err,
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(p.rtts)
+	return &s
+}
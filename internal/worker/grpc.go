@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/grpcprobe"
+)
+
+// GRPC worker iterates over schema.Host tasks, calling Check of gRPC health service of each of them and push results
+// into config.Results channel, service not reported as SERVING has CRITICAL status.
+func GRPC(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := grpcprobe.NewGRPCProbe(device.IP, device.Port, device.Hostname)
+		if err != nil {
+			return err
+		}
+
+		probe.Service = config.Probe.GRPC.Service
+		if service, ok := device.Labels["grpc_service"]; ok {
+			probe.Service = service
+		}
+
+		probe.OnFinish = func(stats *grpcprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("gRPC health check of %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			result.GRPC = &schema.GRPCResult{Service: probe.Service, Status: stats.Status}
+			if stats.Status != "SERVING" {
+				result.Status = schema.StatusCritical
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s:%s grpc health statistics ---\n", stats.Addr, stats.Port)
+			line += fmt.Sprintf("%d requests transmitted, %d responses received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("round-trip min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			if probe.Service != "" {
+				line += fmt.Sprintf("service %s status: %s\n", probe.Service, stats.Status)
+			} else {
+				line += fmt.Sprintf("status: %s\n", stats.Status)
+			}
+			result.Output = append(result.Output, line)
+		}
+
+		probe.TLS = config.Probe.GRPC.TLS
+		probe.InsecureSkipVerify = config.Probe.GRPC.InsecureSkipVerify
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
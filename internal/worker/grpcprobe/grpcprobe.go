@@ -0,0 +1,177 @@
+package grpcprobe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// GRPCProbe calls Check of standard grpc.health.v1.Health service and measures its latency.
+type GRPCProbe struct {
+	ip       string
+	port     string
+	hostname string
+
+	// Service is name of checked service, empty name checks overall health of server.
+	Service string
+
+	// TLS enables TLS transport, hostname is used to verify server certificate.
+	TLS bool
+
+	// InsecureSkipVerify disables verification of server certificate.
+	InsecureSkipVerify bool
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	rtts          []time.Duration
+	status        healthpb.HealthCheckResponse_ServingStatus
+	err           error
+
+	// OnFinish is called when GRPCProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Statistics represent the stats of a GRPCProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is gRPC service port.
+	Port string
+
+	// RequestsSent is the number of requests sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of responses received.
+	ResponsesRecv int
+
+	// Loss is the percentage of requests without response.
+	Loss float64
+
+	// MinRtt is the minimum latency of request.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum latency of request.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average latency of request.
+	AvgRtt time.Duration
+
+	// Status is serving status returned by the last response, e.g. "SERVING" or "NOT_SERVING".
+	Status string
+
+	// Error specifies the last request error.
+	Error error
+}
+
+// NewGRPCProbe returns a new GRPCProbe struct pointer, hostname is used to verify server certificate if specified.
+func NewGRPCProbe(host, port, hostname string) (*GRPCProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	if hostname == "" {
+		hostname = ip.String()
+	}
+
+	return &GRPCProbe{
+		ip:       ip.String(),
+		port:     port,
+		hostname: hostname,
+		Count:    1,
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *GRPCProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *GRPCProbe) run() {
+	creds := insecure.NewCredentials()
+	if p.TLS {
+		creds = credentials.NewTLS(&tls.Config{ServerName: p.hostname, InsecureSkipVerify: p.InsecureSkipVerify})
+	}
+
+	conn, err := grpc.NewClient(net.JoinHostPort(p.ip, p.port), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		p.requestsSent++
+		ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+		start := time.Now()
+		response, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: p.Service})
+		rtt := time.Since(start)
+		cancel()
+		if err != nil {
+			p.err = err
+			continue
+		}
+
+		p.rtts = append(p.rtts, rtt)
+		p.responsesRecv++
+		p.status = response.GetStatus()
+	}
+}
+
+// Statistics returns the statistics of the GRPCProbe.
+func (p *GRPCProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          p.port,
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Error:         p.err,
+	}
+	if p.responsesRecv > 0 {
+		s.Status = p.status.String()
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(p.rtts)
+	return &s
+}
@@ -15,10 +15,16 @@ type Netcat struct {
 	// Timeout specifies tiemout for connection establishing.
 	Timeout time.Duration
 
+	// Script is optional list of send/expect steps run on established connection.
+	Script []Step
+
 	connectionTries        int
 	connectionsEstablished int
 	rtt                    time.Duration
 	err                    error
+	banner                 string
+	failedStep             int
+	scriptErr              error
 
 	// OnFinish is called when Netcat exits
 	OnFinish func(*Statistics)
@@ -49,6 +55,15 @@ type Statistics struct {
 
 	// Rtt is round-trip time of dialing to host.
 	Rtt time.Duration
+
+	// Banner is line of response matched by the first expect step of script.
+	Banner string
+
+	// FailedStep is number of failed script step counted from 1, 0 if script succeeded.
+	FailedStep int
+
+	// ScriptError specifies script failure.
+	ScriptError error
 }
 
 func NewNetcat(host, port string) (*Netcat, error) {
@@ -58,7 +73,7 @@ func NewNetcat(host, port string) (*Netcat, error) {
 	}
 
 	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
-		return nil, fmt.Errorf("wrong port number: %v", p)
+		return nil, fmt.Errorf("wrong port number: %v", port)
 	}
 
 	return &Netcat{
@@ -73,11 +88,14 @@ func (n *Netcat) Run() {
 
 	// TODO refactor this to nonblocking version with tries count > 1
 	connT := time.Now()
-	connection, err := net.DialTimeout("tcp", n.ip+":"+n.port, n.Timeout)
+	connection, err := net.DialTimeout("tcp", net.JoinHostPort(n.ip, n.port), n.Timeout)
 
 	if err == nil {
 		n.connectionsEstablished++
 		n.rtt = time.Since(connT)
+		if len(n.Script) > 0 {
+			n.banner, n.failedStep, n.scriptErr = runScript(connection, n.Script, n.Timeout)
+		}
 		connection.Close()
 	} else {
 		n.err = err
@@ -101,6 +119,9 @@ func (n *Netcat) Statistics() *Statistics {
 		Rtt:                    n.rtt,
 		ConnectionError:        n.err,
 		ConnectionLoss:         loss,
+		Banner:                 n.banner,
+		FailedStep:             n.failedStep,
+		ScriptError:            n.scriptErr,
 	}
 	return &s
 }
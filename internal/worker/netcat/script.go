@@ -0,0 +1,84 @@
+package netcat
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+)
+
+// maxExpectBuffer limits amount of data read while waiting for expected response.
+const maxExpectBuffer = 64 * 1024
+
+// Step is one step of send/expect script run on established connection.
+type Step struct {
+	// Send is written to connection, if empty nothing is sent.
+	Send string
+
+	// Expect is pattern of response awaited after sending data, if nil step doesn't wait for response.
+	Expect *regexp.Regexp
+
+	// Timeout specifies time to wait for expected response.
+	Timeout time.Duration
+}
+
+// runScript runs steps on connection, returns line of response matched by the first expect step (banner),
+// number of failed step (counted from 1) and error.
+func runScript(conn net.Conn, steps []Step, timeout time.Duration) (string, int, error) {
+	var banner string
+	var buf []byte
+	chunk := make([]byte, 4096)
+
+	for i, step := range steps {
+		if step.Send != "" {
+			conn.SetWriteDeadline(time.Now().Add(timeout))
+			if _, err := conn.Write([]byte(step.Send)); err != nil {
+				return banner, i + 1, err
+			}
+		}
+
+		if step.Expect == nil {
+			continue
+		}
+
+		stepTimeout := step.Timeout
+		if stepTimeout == 0 {
+			stepTimeout = timeout
+		}
+		conn.SetReadDeadline(time.Now().Add(stepTimeout))
+
+		for {
+			if loc := step.Expect.FindIndex(buf); loc != nil {
+				if banner == "" {
+					banner = line(buf, loc[0])
+				}
+				// consume response up to the match, next step expects data sent after it
+				buf = buf[loc[1]:]
+				break
+			}
+
+			if len(buf) > maxExpectBuffer {
+				return banner, i + 1, fmt.Errorf("expected %q not found in %d bytes of response", step.Expect, len(buf))
+			}
+
+			n, err := conn.Read(chunk)
+			if err != nil {
+				return banner, i + 1, fmt.Errorf("expected %q not received: %v", step.Expect, err)
+			}
+			buf = append(buf, chunk[:n]...)
+		}
+	}
+	return banner, 0, nil
+}
+
+// line returns whole line of buf containing byte at position pos.
+func line(buf []byte, pos int) string {
+	start, end := pos, pos
+	for start > 0 && buf[start-1] != '\n' {
+		start--
+	}
+	for end < len(buf) && buf[end] != '\r' && buf[end] != '\n' {
+		end++
+	}
+	return string(buf[start:end])
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/ntpprobe"
+)
+
+// NTP worker iterates over schema.Host tasks, sending SNTP requests to each of them and push results into config.Results channel,
+// clock offset exceeding configured thresholds is reported as WARNING or CRITICAL status.
+func NTP(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := ntpprobe.NewNTPProbe(device.IP, device.Port)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *ntpprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgDelay.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("NTP request to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			result.NTP = &schema.NTPResult{
+				Offset:      stats.Offset.Seconds(),
+				Delay:       stats.MinDelay.Seconds(),
+				Stratum:     stats.Stratum,
+				ReferenceID: stats.ReferenceID,
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s ntp statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d requests transmitted, %d responses received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("delay min/avg/max = %v/%v/%v\n", toMs(stats.MinDelay), toMs(stats.AvgDelay), toMs(stats.MaxDelay))
+			line += fmt.Sprintf("offset=%v, stratum=%d, refid=%s\n", toMs(stats.Offset), stats.Stratum, stats.ReferenceID)
+
+			offset := stats.Offset
+			if offset < 0 {
+				offset = -offset
+			}
+			if threshold := config.Probe.NTP.CriticalOffset.Duration; threshold > 0 && offset > threshold {
+				result.Status = schema.StatusCritical
+				line += fmt.Sprintf("Clock offset of %s exceeds %v!\n", stats.Addr, threshold)
+			} else if threshold := config.Probe.NTP.WarningOffset.Duration; threshold > 0 && offset > threshold {
+				result.Status = schema.StatusWarning
+				line += fmt.Sprintf("Clock offset of %s exceeds %v!\n", stats.Addr, threshold)
+			}
+
+			result.Output = append(result.Output, line)
+		}
+
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
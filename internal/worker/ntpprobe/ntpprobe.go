@@ -0,0 +1,253 @@
+package ntpprobe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+const (
+	packetSize = 48
+
+	// seconds between NTP era (1900) and Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+
+	modeClient = 3
+	modeServer = 4
+	version    = 4
+)
+
+// NTPProbe sends SNTP client requests to server and measures clock offset and round-trip delay.
+type NTPProbe struct {
+	ip   string
+	port string
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	samples       []sample
+	err           error
+
+	// OnFinish is called when NTPProbe exits
+	OnFinish func(*Statistics)
+}
+
+// sample is the result of one request.
+type sample struct {
+	offset      time.Duration
+	delay       time.Duration
+	stratum     uint8
+	referenceID string
+}
+
+// Statistics represent the stats of a NTPProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is NTP service port.
+	Port string
+
+	// RequestsSent is the number of requests sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of valid responses received.
+	ResponsesRecv int
+
+	// Loss is the percentage of requests lost.
+	Loss float64
+
+	// MinDelay is the minimum round-trip delay.
+	MinDelay time.Duration
+
+	// MaxDelay is the maximum round-trip delay.
+	MaxDelay time.Duration
+
+	// AvgDelay is the average round-trip delay.
+	AvgDelay time.Duration
+
+	// Offset is the clock offset of server measured by response with the lowest delay, positive if server is ahead.
+	Offset time.Duration
+
+	// Stratum is the stratum of server.
+	Stratum uint8
+
+	// ReferenceID is the reference clock of server, name of source for stratum 1 or address of upstream server.
+	ReferenceID string
+
+	// Error specifies the last request error.
+	Error error
+}
+
+// NewNTPProbe returns a new NTPProbe struct pointer.
+func NewNTPProbe(host, port string) (*NTPProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	return &NTPProbe{
+		ip:       ip.String(),
+		port:     port,
+		Count:    1,
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *NTPProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *NTPProbe) run() {
+	conn, err := net.Dial("udp", net.JoinHostPort(p.ip, p.port))
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		p.requestsSent++
+		s, err := query(conn, p.Timeout)
+		if err != nil {
+			p.err = err
+			continue
+		}
+		p.responsesRecv++
+		p.samples = append(p.samples, s)
+	}
+}
+
+// query sends one client request and decodes server response.
+func query(conn net.Conn, timeout time.Duration) (sample, error) {
+	request := make([]byte, packetSize)
+	request[0] = version<<3 | modeClient
+
+	// server copies transmit timestamp into origin timestamp of response, it's used to match response
+	t1 := time.Now()
+	origin := toNTPTime(t1)
+	binary.BigEndian.PutUint64(request[40:], origin)
+
+	conn.SetDeadline(t1.Add(timeout))
+	if _, err := conn.Write(request); err != nil {
+		return sample{}, err
+	}
+
+	response := make([]byte, packetSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return sample{}, err
+		}
+		t4 := time.Now()
+
+		if n < packetSize || binary.BigEndian.Uint64(response[24:]) != origin {
+			// late response of previous request or garbage
+			continue
+		}
+		if mode := response[0] & 0x7; mode != modeServer {
+			return sample{}, fmt.Errorf("unexpected mode %d of response", mode)
+		}
+
+		stratum := response[1]
+		if stratum == 0 {
+			return sample{}, fmt.Errorf("kiss of death %s", strings.TrimRight(string(response[12:16]), "\x00"))
+		}
+		if response[0]>>6 == 3 {
+			return sample{}, fmt.Errorf("server clock not synchronized")
+		}
+
+		t2 := fromNTPTime(binary.BigEndian.Uint64(response[32:]))
+		t3 := fromNTPTime(binary.BigEndian.Uint64(response[40:]))
+
+		return sample{
+			offset:      (t2.Sub(t1) + t3.Sub(t4)) / 2,
+			delay:       t4.Sub(t1) - t3.Sub(t2),
+			stratum:     stratum,
+			referenceID: referenceID(stratum, response[12:16]),
+		}, nil
+	}
+}
+
+// referenceID formats reference ID, for stratum 1 it's name of clock source, otherwise IPv4 address of upstream server
+// (or hash of IPv6 address).
+func referenceID(stratum uint8, id []byte) string {
+	if stratum == 1 {
+		return strings.TrimRight(string(id), "\x00")
+	}
+	return net.IP(id).String()
+}
+
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// Statistics returns the statistics of the NTPProbe.
+func (p *NTPProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          p.port,
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Error:         p.err,
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	if len(p.samples) > 0 {
+		var delays []time.Duration
+		best := p.samples[0]
+		for _, sample := range p.samples {
+			// sample with the lowest delay gives the most accurate offset
+			if sample.delay < best.delay {
+				best = sample
+			}
+			delays = append(delays, sample.delay)
+		}
+		s.MinDelay, s.AvgDelay, s.MaxDelay = rttstats.MinAvgMax(delays)
+		s.Offset = best.offset
+		s.Stratum = best.stratum
+		s.ReferenceID = best.referenceID
+	}
+	return &s
+}
@@ -3,7 +3,7 @@
 package goping
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -12,15 +12,18 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/migotom/uberping/internal/worker/rttstats"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
 
 const (
-	timeSliceLength  = 8
 	protocolICMP     = 1
 	protocolIPv6ICMP = 58
+
+	maxIPv4HeaderLength = 60
+	icmpHeaderLength    = 8
 )
 
 var (
@@ -52,7 +55,8 @@ func NewPinger(addr string) (*Pinger, error) {
 		id:       r.Intn(math.MaxInt16),
 		network:  "udp",
 		ipv4:     ipv4,
-		Size:     timeSliceLength,
+		Size:     HeaderLength,
+		Pattern:  FixedPattern(1),
 		Tracker:  r.Int63n(math.MaxInt64),
 		done:     make(chan bool),
 	}, nil
@@ -63,6 +67,10 @@ type Pinger struct {
 	// Interval is the wait time between each packet send. Default is 1s.
 	Interval time.Duration
 
+	// Pace is called before sending each packet and may delay it, e.g. to limit packets per second
+	// sent by all pingers together.
+	Pace func()
+
 	// Timeout specifies a timeout before ping exits, regardless of how many
 	// packets have been received.
 	Timeout time.Duration
@@ -81,9 +89,18 @@ type Pinger struct {
 	// Number of packets received
 	PacketsRecv int
 
+	// Number of replies with payload different from sent
+	PacketsCorrupted int
+
 	// rtts is all of the Rtts
 	rtts []time.Duration
 
+	// icmpErrors counts ICMP error messages caused by sent requests, errorsRecv is the number of requests
+	// reported as undeliverable, errored keeps their sequence numbers
+	icmpErrors map[ICMPError]int
+	errorsRecv int
+	errored    map[int]bool
+
 	// OnRecv is called when Pinger receives and processes a packet
 	OnRecv func(*Packet)
 
@@ -93,9 +110,34 @@ type Pinger struct {
 	// Size of packet being sent
 	Size int
 
+	// Pattern fills payload following send time, replies are verified against it
+	Pattern Pattern
+
 	// Tracker: Used to uniquely identify packet when non-priviledged
 	Tracker int64
 
+	// KernelTimestamps tells pinger to compute round-trip times using kernel receive timestamps,
+	// only Linux is supported, otherwise packets are timestamped when read.
+	KernelTimestamps bool
+
+	// timestampSource is the source of receive time actually used
+	timestampSource string
+
+	// Timestamp tells pinger to send ICMP Timestamp Requests instead of Echo Requests
+	// and estimate one-way delays, only privileged IPv4 ping is supported.
+	Timestamp bool
+
+	// sent keeps send times of timestamp requests by sequence number
+	sent map[uint16]time.Time
+
+	// forwards, reverses and offsets are one-way delays and clock offsets estimated by timestamp replies
+	forwards []time.Duration
+	reverses []time.Duration
+	offsets  []time.Duration
+
+	// err is the error which stopped pinger before sending requests
+	err error
+
 	// stop chan bool
 	done chan bool
 
@@ -111,8 +153,10 @@ type Pinger struct {
 }
 
 type packet struct {
-	bytes  []byte
-	nbytes int
+	bytes    []byte
+	nbytes   int
+	from     net.Addr
+	received time.Time
 }
 
 // Packet represents a received and processed ICMP echo packet.
@@ -131,6 +175,24 @@ type Packet struct {
 
 	// Seq is the ICMP sequence number.
 	Seq int
+
+	// Corrupted specifies if payload of reply was different from sent, Rtt is unknown then.
+	Corrupted bool
+
+	// Error is ICMP error message received instead of reply, Rtt is unknown then.
+	Error *ICMPError
+
+	// OneWay specifies if one-way delays were estimated using timestamp reply.
+	OneWay bool
+
+	// Forward is the estimated one-way delay to host, biased by clock offset.
+	Forward time.Duration
+
+	// Reverse is the estimated one-way delay from host, biased by clock offset.
+	Reverse time.Duration
+
+	// Offset is the estimated clock offset of host, positive if host clock is ahead.
+	Offset time.Duration
 }
 
 // Statistics represent the stats of a currently running or finished
@@ -142,9 +204,18 @@ type Statistics struct {
 	// PacketsSent is the number of packets sent.
 	PacketsSent int
 
-	// PacketLoss is the percentage of packets lost.
+	// PacketsCorrupted is the number of replies with payload different from sent.
+	PacketsCorrupted int
+
+	// PacketLoss is the percentage of packets lost, corrupted replies are not counted as lost.
 	PacketLoss float64
 
+	// PacketCorruption is the percentage of packets corrupted.
+	PacketCorruption float64
+
+	// Errors counts ICMP error messages received in response to sent requests, their requests are counted as lost.
+	Errors map[ICMPError]int
+
 	// IPAddr is the address of the host being pinged.
 	IPAddr *net.IPAddr
 
@@ -166,6 +237,30 @@ type Statistics struct {
 	// StdDevRtt is the standard deviation of the round-trip times sent via
 	// this pinger.
 	StdDevRtt time.Duration
+
+	// TimestampSource is the source of receive time used to compute round-trip times, kernel or user.
+	TimestampSource string
+
+	// OneWay specifies if one-way delays were estimated using timestamp replies.
+	OneWay bool
+
+	// AvgForward is the average estimated one-way delay to host.
+	AvgForward time.Duration
+
+	// MaxForward is the maximum estimated one-way delay to host.
+	MaxForward time.Duration
+
+	// AvgReverse is the average estimated one-way delay from host.
+	AvgReverse time.Duration
+
+	// MaxReverse is the maximum estimated one-way delay from host.
+	MaxReverse time.Duration
+
+	// AvgOffset is the average estimated clock offset of host.
+	AvgOffset time.Duration
+
+	// Error specifies why pinger couldn't send requests, other statistics are empty then.
+	Error error
 }
 
 // SetIPAddr sets the ip address of the target host.
@@ -230,15 +325,22 @@ func (p *Pinger) Run() {
 }
 
 func (p *Pinger) run() {
-	var conn *icmp.PacketConn
+	if p.Timestamp && (!p.ipv4 || !p.Privileged()) {
+		p.err = errors.New("ICMP timestamp requires privileged IPv4 ping")
+		close(p.done)
+		p.finish()
+		return
+	}
+
+	var conn net.PacketConn
 	if p.ipv4 {
-		if conn = p.listen(ipv4Proto[p.network], p.source); conn == nil {
-			return
-		}
+		conn = p.listen(ipv4Proto[p.network], p.source)
 	} else {
-		if conn = p.listen(ipv6Proto[p.network], p.source); conn == nil {
-			return
-		}
+		conn = p.listen(ipv6Proto[p.network], p.source)
+	}
+	if conn == nil {
+		p.finish()
+		return
 	}
 	defer conn.Close()
 	defer p.finish()
@@ -282,7 +384,7 @@ func (p *Pinger) run() {
 				fmt.Println("FATAL: ", err.Error())
 			}
 		}
-		if p.Count > 0 && p.PacketsRecv >= p.Count {
+		if p.Count > 0 && p.PacketsRecv+p.PacketsCorrupted+p.errorsRecv >= p.Count {
 			close(p.done)
 			wg.Wait()
 			return
@@ -306,7 +408,11 @@ func (p *Pinger) finish() {
 // pinger is running or after it is finished. OnFinish calls this function to
 // get it's finished statistics.
 func (p *Pinger) Statistics() *Statistics {
-	loss := float64(p.PacketsSent-p.PacketsRecv) / float64(p.PacketsSent) * 100
+	if p.err != nil {
+		return &Statistics{Addr: p.addr, IPAddr: p.ipaddr, PacketLoss: 100, Error: p.err}
+	}
+
+	loss := float64(p.PacketsSent-p.PacketsRecv-p.PacketsCorrupted) / float64(p.PacketsSent) * 100
 	var min, max, total time.Duration
 	if len(p.rtts) > 0 {
 		min = p.rtts[0]
@@ -322,14 +428,18 @@ func (p *Pinger) Statistics() *Statistics {
 		total += rtt
 	}
 	s := Statistics{
-		PacketsSent: p.PacketsSent,
-		PacketsRecv: p.PacketsRecv,
-		PacketLoss:  loss,
-		Rtts:        p.rtts,
-		Addr:        p.addr,
-		IPAddr:      p.ipaddr,
-		MaxRtt:      max,
-		MinRtt:      min,
+		PacketsSent:      p.PacketsSent,
+		TimestampSource:  p.timestampSource,
+		PacketsRecv:      p.PacketsRecv,
+		PacketsCorrupted: p.PacketsCorrupted,
+		PacketLoss:       loss,
+		PacketCorruption: float64(p.PacketsCorrupted) / float64(p.PacketsSent) * 100,
+		Errors:           p.icmpErrors,
+		Rtts:             p.rtts,
+		Addr:             p.addr,
+		IPAddr:           p.ipaddr,
+		MaxRtt:           max,
+		MinRtt:           min,
 	}
 	if len(p.rtts) > 0 {
 		s.AvgRtt = total / time.Duration(len(p.rtts))
@@ -340,11 +450,17 @@ func (p *Pinger) Statistics() *Statistics {
 		s.StdDevRtt = time.Duration(math.Sqrt(
 			float64(sumsquares / time.Duration(len(p.rtts)))))
 	}
+	if len(p.forwards) > 0 {
+		s.OneWay = true
+		_, s.AvgForward, s.MaxForward = rttstats.MinAvgMax(p.forwards)
+		_, s.AvgReverse, s.MaxReverse = rttstats.MinAvgMax(p.reverses)
+		_, s.AvgOffset, _ = rttstats.MinAvgMax(p.offsets)
+	}
 	return &s
 }
 
 func (p *Pinger) recvICMP(
-	conn *icmp.PacketConn,
+	conn net.PacketConn,
 	recv chan<- *packet,
 	wg *sync.WaitGroup,
 ) {
@@ -354,9 +470,18 @@ func (p *Pinger) recvICMP(
 		case <-p.done:
 			return
 		default:
-			bytes := make([]byte, 512)
+			bytes := make([]byte, p.receiveLength())
 			conn.SetReadDeadline(time.Now().Add(p.Timeout))
-			n, _, err := conn.ReadFrom(bytes)
+			var n int
+			var from net.Addr
+			var received time.Time
+			var err error
+			if reader, ok := conn.(timestampedReader); ok {
+				n, from, received, err = reader.ReadTimestamped(bytes)
+			} else {
+				n, from, err = conn.ReadFrom(bytes)
+				received = time.Now()
+			}
 			if err != nil {
 				if neterr, ok := err.(*net.OpError); ok {
 					if neterr.Timeout() {
@@ -368,9 +493,9 @@ func (p *Pinger) recvICMP(
 					}
 				}
 			}
-			recv <- &packet{bytes: bytes, nbytes: n}
+			recv <- &packet{bytes: bytes, nbytes: n, from: from, received: received}
 
-			if p.PacketsRecv+1 >= p.Count {
+			if p.PacketsRecv+p.PacketsCorrupted+p.errorsRecv+1 >= p.Count {
 				// No need to keep gorutine still active, that was last packet
 				return
 			}
@@ -378,75 +503,86 @@ func (p *Pinger) recvICMP(
 	}
 }
 
+// receiveLength returns size of buffer fitting reply with payload of p.Size bytes, IPv4 header with options
+// and ICMP header, but not less than 512 bytes needed by ICMP errors quoting requests.
+func (p *Pinger) receiveLength() int {
+	if n := p.Size + maxIPv4HeaderLength + icmpHeaderLength; n > 512 {
+		return n
+	}
+	return 512
+}
+
 func (p *Pinger) processPacket(recv *packet) error {
 	var bytes []byte
 	var proto int
 	if p.ipv4 {
 		if p.network == "ip" {
-			bytes = ipv4Payload(recv.bytes)
+			bytes = ipv4Payload(recv.bytes[:recv.nbytes])
 		} else {
-			bytes = recv.bytes
+			bytes = recv.bytes[:recv.nbytes]
 		}
 		proto = protocolICMP
 	} else {
-		bytes = recv.bytes
+		bytes = recv.bytes[:recv.nbytes]
 		proto = protocolIPv6ICMP
 	}
 
+	// size of ICMP message, regardless if socket delivers it with IP header
+	recv.nbytes = len(bytes)
+
 	var m *icmp.Message
 	var err error
-	if m, err = icmp.ParseMessage(proto, bytes[:recv.nbytes]); err != nil {
+	if m, err = icmp.ParseMessage(proto, bytes); err != nil {
 		return fmt.Errorf("Error parsing icmp message")
 	}
 
+	if m.Type == ipv4.ICMPTypeTimestampReply && p.Timestamp {
+		return p.processTimestampReply(m, recv)
+	}
+
 	if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
-		// Not an echo reply, ignore it
-		return nil
+		// Not an echo reply, count it if it's error caused by our request
+		return p.processError(m, recv)
 	}
 
-	body := m.Body.(*icmp.Echo)
+	body, ok := m.Body.(*icmp.Echo)
+	if !ok {
+		// Very bad, not sure how this can happen
+		return fmt.Errorf("Error, invalid ICMP echo reply. Body type: %T, %s",
+			m.Body, m.Body)
+	}
+
+	data, ok := parsePayload(body.Data)
 	// If we are priviledged, we can match icmp.ID
 	if p.network == "ip" {
 		// Check if reply from same ID
 		if body.ID != p.id {
 			return nil
 		}
-	} else {
+	} else if ok && data.tracker != p.Tracker {
 		// If we are not priviledged, we cannot set ID - require kernel ping_table map
-		// need to use contents to identify packet
-		data := IcmpData{}
-		err := json.Unmarshal(body.Data, &data)
-		if err != nil {
-			return err
-		}
-		if data.Tracker != p.Tracker {
-			return nil
-		}
+		// need to use contents to identify packet, kernel delivers only replies to our socket
+		// so reply which can't be decoded is corrupted one
+		return nil
 	}
 
 	outPkt := &Packet{
 		Nbytes: recv.nbytes,
 		IPAddr: p.ipaddr,
 		Addr:   p.addr,
+		Seq:    body.Seq,
 	}
 
-	switch pkt := m.Body.(type) {
-	case *icmp.Echo:
-		data := IcmpData{}
-		err := json.Unmarshal(m.Body.(*icmp.Echo).Data, &data)
-		if err != nil {
-			return err
-		}
-		outPkt.Rtt = time.Since(bytesToTime(data.Bytes))
-		outPkt.Seq = pkt.Seq
+	if !ok || len(body.Data) != p.Size || data.tracker != p.Tracker || int(uint16(data.seq)) != body.Seq ||
+		!verify(p.Pattern, body.Data[HeaderLength:], body.Seq) {
+		outPkt.Corrupted = true
+		p.PacketsCorrupted += 1
+	} else {
+		outPkt.Rtt = recv.received.Sub(data.sent)
 		p.PacketsRecv += 1
-	default:
-		// Very bad, not sure how this can happen
-		return fmt.Errorf("Error, invalid ICMP echo reply. Body type: %T, %s",
-			pkt, pkt)
+		p.rtts = append(p.rtts, outPkt.Rtt)
 	}
 
-	p.rtts = append(p.rtts, outPkt.Rtt)
 	handler := p.OnRecv
 	if handler != nil {
 		handler(outPkt)
@@ -455,12 +591,11 @@ func (p *Pinger) processPacket(recv *packet) error {
 	return nil
 }
 
-type IcmpData struct {
-	Bytes   []byte
-	Tracker int64
-}
+func (p *Pinger) sendICMP(conn net.PacketConn) error {
+	if p.Pace != nil {
+		p.Pace()
+	}
 
-func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 	var typ icmp.Type
 	if p.ipv4 {
 		typ = ipv4.ICMPTypeEcho
@@ -473,19 +608,20 @@ func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 		dst = &net.UDPAddr{IP: p.ipaddr.IP, Zone: p.ipaddr.Zone}
 	}
 
-	t := timeToBytes(time.Now())
-	if p.Size-timeSliceLength != 0 {
-		t = append(t, byteSliceOfSize(p.Size-timeSliceLength)...)
-	}
-
-	data, err := json.Marshal(IcmpData{Bytes: t, Tracker: p.Tracker})
-	if err != nil {
-		return fmt.Errorf("Unable to marshal data %s", err)
-	}
-	body := &icmp.Echo{
-		ID:   p.id,
-		Seq:  p.sequence,
-		Data: data,
+	var body icmp.MessageBody
+	if p.Timestamp {
+		typ = ipv4.ICMPTypeTimestamp
+		body = p.timestampBody()
+	} else {
+		data := make([]byte, p.Size)
+		p.Pattern.Fill(data[HeaderLength:], int(uint16(p.sequence)))
+		payload{tracker: p.Tracker, seq: uint32(p.sequence), sent: time.Now()}.marshal(data)
+
+		body = &icmp.Echo{
+			ID:   p.id,
+			Seq:  p.sequence,
+			Data: data,
+		}
 	}
 	msg := &icmp.Message{
 		Type: typ,
@@ -512,41 +648,34 @@ func (p *Pinger) sendICMP(conn *icmp.PacketConn) error {
 	return nil
 }
 
-func (p *Pinger) listen(netProto string, source string) *icmp.PacketConn {
+func (p *Pinger) listen(netProto string, source string) net.PacketConn {
+	p.timestampSource = TimestampSourceUser
+	if p.KernelTimestamps {
+		// fall back to timestamps of pinger if kernel ones can't be enabled
+		if conn, err := listenTimestamped(netProto, source); err == nil {
+			p.timestampSource = TimestampSourceKernel
+			return conn
+		}
+	}
+
 	conn, err := icmp.ListenPacket(netProto, source)
 	if err != nil {
-		fmt.Printf("Error listening for ICMP packets: %s\n", err.Error())
+		p.err = fmt.Errorf("error listening for ICMP packets: %v", err)
 		close(p.done)
 		return nil
 	}
 	return conn
 }
 
-func byteSliceOfSize(n int) []byte {
-	b := make([]byte, n)
-	for i := 0; i < len(b); i++ {
-		b[i] = 1
-	}
-
-	return b
-}
-
 func ipv4Payload(b []byte) []byte {
-	if len(b) < ipv4.HeaderLen {
+	// IPv4 header is included only by some platforms, e.g. Linux returns ICMP message alone
+	if len(b) < ipv4.HeaderLen || b[0]>>4 != ipv4.Version {
 		return b
 	}
 	hdrlen := int(b[0]&0x0f) << 2
 	return b[hdrlen:]
 }
 
-func bytesToTime(b []byte) time.Time {
-	var nsec int64
-	for i := uint8(0); i < 8; i++ {
-		nsec += int64(b[i]) << ((7 - i) * 8)
-	}
-	return time.Unix(nsec/1000000000, nsec%1000000000)
-}
-
 func isIPv4(ip net.IP) bool {
 	return len(ip.To4()) == net.IPv4len
 }
@@ -554,12 +683,3 @@ func isIPv4(ip net.IP) bool {
 func isIPv6(ip net.IP) bool {
 	return len(ip) == net.IPv6len
 }
-
-func timeToBytes(t time.Time) []byte {
-	nsec := t.UnixNano()
-	b := make([]byte, 8)
-	for i := uint8(0); i < 8; i++ {
-		b[i] = byte((nsec >> ((7 - i) * 8)) & 0xff)
-	}
-	return b
-}
@@ -0,0 +1,22 @@
+package goping
+
+import "testing"
+
+func TestListenError(t *testing.T) {
+	pinger, err := NewPinger("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats *Statistics
+	pinger.OnFinish = func(s *Statistics) {
+		stats = s
+	}
+	// source address which isn't local can't be bound
+	pinger.source = "192.0.2.1"
+	pinger.Run()
+
+	if stats == nil || stats.Error == nil || stats.PacketLoss != 100 {
+		t.Errorf("expected statistics with listen error, got: %v", stats)
+	}
+}
@@ -0,0 +1,157 @@
+package goping
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPError is ICMP error message received in response to sent request, e.g. destination unreachable reported
+// by firewall filtering requests.
+type ICMPError struct {
+	// Type and Code of ICMP message.
+	Type int
+	Code int
+
+	// Description is human readable type and code of ICMP message.
+	Description string
+
+	// From is the address of host or router which reported error.
+	From string
+}
+
+func (e ICMPError) String() string {
+	return fmt.Sprintf("%s from %s", e.Description, e.From)
+}
+
+// descriptions of codes of ICMP error types, RFC 792, RFC 1812 and RFC 4443
+var (
+	ipv4Codes = map[icmp.Type][]string{
+		ipv4.ICMPTypeDestinationUnreachable: {
+			"network unreachable", "host unreachable", "protocol unreachable", "port unreachable",
+			"fragmentation needed", "source route failed", "destination network unknown", "destination host unknown",
+			"source host isolated", "network administratively prohibited", "host administratively prohibited",
+			"network unreachable for TOS", "host unreachable for TOS", "communication administratively prohibited",
+			"host precedence violation", "precedence cutoff in effect",
+		},
+		ipv4.ICMPTypeTimeExceeded:     {"TTL exceeded in transit", "fragment reassembly time exceeded"},
+		ipv4.ICMPTypeRedirect:         {"redirect for network", "redirect for host", "redirect for TOS and network", "redirect for TOS and host"},
+		ipv4.ICMPTypeParameterProblem: {"pointer indicates error", "missing required option", "bad length"},
+	}
+	ipv6Codes = map[icmp.Type][]string{
+		ipv6.ICMPTypeDestinationUnreachable: {
+			"no route to destination", "communication administratively prohibited", "beyond scope of source address",
+			"address unreachable", "port unreachable", "source address failed ingress/egress policy", "reject route to destination",
+		},
+		ipv6.ICMPTypePacketTooBig:     {"packet too big"},
+		ipv6.ICMPTypeTimeExceeded:     {"hop limit exceeded in transit", "fragment reassembly time exceeded"},
+		ipv6.ICMPTypeParameterProblem: {"erroneous header field", "unrecognized next header", "unrecognized IPv6 option"},
+	}
+)
+
+// description returns type and code of ICMP message in human readable form.
+func description(typ icmp.Type, code int) string {
+	codes := ipv4Codes[typ]
+	if _, ok := typ.(ipv6.ICMPType); ok {
+		codes = ipv6Codes[typ]
+	}
+	if code >= 0 && code < len(codes) {
+		return fmt.Sprintf("%v, %s", typ, codes[code])
+	}
+	return fmt.Sprintf("%v, code %d", typ, code)
+}
+
+// quoted returns original datagram quoted by ICMP error message, or nil if message isn't error.
+func quoted(m *icmp.Message) []byte {
+	switch body := m.Body.(type) {
+	case *icmp.DstUnreach:
+		return body.Data
+	case *icmp.TimeExceeded:
+		return body.Data
+	case *icmp.ParamProb:
+		return body.Data
+	case *icmp.PacketTooBig:
+		return body.Data
+	case *icmp.RawBody:
+		// redirect quotes datagram following address of gateway
+		if m.Type == ipv4.ICMPTypeRedirect && len(body.Data) > net.IPv4len {
+			return body.Data[net.IPv4len:]
+		}
+	}
+	return nil
+}
+
+// quotedRequest returns destination, ICMP type, identifier and sequence number of request quoted by ICMP error message.
+func quotedRequest(datagram []byte) (dst net.IP, typ, id, seq int, ok bool) {
+	var request []byte
+	switch {
+	case len(datagram) >= ipv4.HeaderLen && datagram[0]>>4 == ipv4.Version:
+		hdrlen := int(datagram[0]&0x0f) << 2
+		if datagram[9] != protocolICMP || len(datagram) < hdrlen+8 {
+			return nil, 0, 0, 0, false
+		}
+		dst, request = net.IP(datagram[16:20]), datagram[hdrlen:]
+	case len(datagram) >= ipv6.HeaderLen && datagram[0]>>4 == ipv6.Version:
+		if datagram[6] != protocolIPv6ICMP || len(datagram) < ipv6.HeaderLen+8 {
+			return nil, 0, 0, 0, false
+		}
+		dst, request = net.IP(datagram[24:40]), datagram[ipv6.HeaderLen:]
+	default:
+		return nil, 0, 0, 0, false
+	}
+	return dst, int(request[0]), int(binary.BigEndian.Uint16(request[4:6])), int(binary.BigEndian.Uint16(request[6:8])), true
+}
+
+// processError matches ICMP error message with request which caused it and counts it, messages caused by other
+// requests are ignored.
+func (p *Pinger) processError(m *icmp.Message, recv *packet) error {
+	dst, typ, id, seq, ok := quotedRequest(quoted(m))
+	if !ok || !dst.Equal(p.ipaddr.IP) || id != p.id {
+		return nil
+	}
+	switch typ {
+	case int(ipv4.ICMPTypeEcho), int(ipv4.ICMPTypeTimestamp), int(ipv6.ICMPTypeEchoRequest):
+	default:
+		return nil
+	}
+
+	icmpErr := ICMPError{Code: m.Code, Description: description(m.Type, m.Code)}
+	switch t := m.Type.(type) {
+	case ipv4.ICMPType:
+		icmpErr.Type = int(t)
+	case ipv6.ICMPType:
+		icmpErr.Type = int(t)
+	}
+	if from, ok := recv.from.(*net.IPAddr); ok {
+		icmpErr.From = from.IP.String()
+	} else if recv.from != nil {
+		icmpErr.From = recv.from.String()
+	}
+
+	if p.icmpErrors == nil {
+		p.icmpErrors = make(map[ICMPError]int)
+		p.errored = make(map[int]bool)
+	}
+	p.icmpErrors[icmpErr]++
+	// redirected request may still be answered
+	if m.Type != ipv4.ICMPTypeRedirect && !p.errored[seq] {
+		p.errored[seq] = true
+		p.errorsRecv++
+	}
+
+	handler := p.OnRecv
+	if handler != nil {
+		handler(&Packet{
+			Nbytes: recv.nbytes,
+			IPAddr: p.ipaddr,
+			Addr:   p.addr,
+			Seq:    seq,
+			Error:  &icmpErr,
+		})
+	}
+	return nil
+}
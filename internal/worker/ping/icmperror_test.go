@@ -0,0 +1,66 @@
+package goping
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestICMPErrors(t *testing.T) {
+	p, err := NewPinger("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPrivileged(true)
+
+	// error message quoting IPv4 header and echo request sent to dst with identifier id
+	icmpError := func(typ ipv4.ICMPType, code int, dst string, id, seq int) *packet {
+		request, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, HeaderLength)}}).Marshal(nil)
+		header := &ipv4.Header{Version: ipv4.Version, Len: ipv4.HeaderLen, TotalLen: ipv4.HeaderLen + len(request), TTL: 1,
+			Protocol: protocolICMP, Src: net.ParseIP("198.51.100.1"), Dst: net.ParseIP(dst)}
+		datagram, _ := header.Marshal()
+		datagram = append(datagram, request[:8]...)
+
+		var body icmp.MessageBody = &icmp.DstUnreach{Data: datagram}
+		if typ == ipv4.ICMPTypeTimeExceeded {
+			body = &icmp.TimeExceeded{Data: datagram}
+		}
+		b, _ := (&icmp.Message{Type: typ, Code: code, Body: body}).Marshal(nil)
+		return &packet{bytes: b, nbytes: len(b), from: &net.IPAddr{IP: net.ParseIP("10.1.1.1")}}
+	}
+
+	var reported []string
+	p.OnRecv = func(pkt *Packet) {
+		if pkt.Error != nil {
+			reported = append(reported, pkt.Error.String())
+		}
+	}
+	for _, pkt := range []*packet{
+		icmpError(ipv4.ICMPTypeDestinationUnreachable, 13, "192.0.2.1", p.id, 0),
+		icmpError(ipv4.ICMPTypeDestinationUnreachable, 13, "192.0.2.1", p.id, 1),
+		icmpError(ipv4.ICMPTypeTimeExceeded, 0, "192.0.2.1", p.id, 2),
+		// caused by other pinger or sent to other host
+		icmpError(ipv4.ICMPTypeDestinationUnreachable, 1, "192.0.2.1", p.id+1, 3),
+		icmpError(ipv4.ICMPTypeDestinationUnreachable, 1, "192.0.2.2", p.id, 3),
+	} {
+		if err := p.processPacket(pkt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.PacketsSent = 4
+
+	s := p.Statistics()
+	prohibited := ICMPError{Type: 3, Code: 13, Description: "destination unreachable, communication administratively prohibited", From: "10.1.1.1"}
+	exceeded := ICMPError{Type: 11, Code: 0, Description: "time exceeded, TTL exceeded in transit", From: "10.1.1.1"}
+	if len(s.Errors) != 2 || s.Errors[prohibited] != 2 || s.Errors[exceeded] != 1 {
+		t.Errorf("invalid ICMP errors, got: %v", s.Errors)
+	}
+	if len(reported) != 3 || reported[0] != "destination unreachable, communication administratively prohibited from 10.1.1.1" {
+		t.Errorf("invalid reported errors, got: %v", reported)
+	}
+	if s.PacketLoss != 100 || p.errorsRecv != 3 {
+		t.Errorf("expected requests reported by errors counted as lost, got: %v%% loss, %d errors", s.PacketLoss, p.errorsRecv)
+	}
+}
@@ -0,0 +1,76 @@
+package goping
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Pattern fills payload of echo request with sequence number seq, replies are verified by filling payload again
+// so the same sequence must give the same payload.
+type Pattern interface {
+	Fill(b []byte, seq int)
+}
+
+// FixedPattern fills payload with one byte.
+type FixedPattern byte
+
+// Fill implements Pattern.
+func (p FixedPattern) Fill(b []byte, seq int) {
+	for i := range b {
+		b[i] = byte(p)
+	}
+}
+
+// IncrementingPattern fills payload with bytes incremented by one, starting from zero.
+type IncrementingPattern struct{}
+
+// Fill implements Pattern.
+func (p IncrementingPattern) Fill(b []byte, seq int) {
+	for i := range b {
+		b[i] = byte(i)
+	}
+}
+
+// RandomPattern fills payload with pseudo random bytes generated by seed and sequence number.
+type RandomPattern int64
+
+// Fill implements Pattern.
+func (p RandomPattern) Fill(b []byte, seq int) {
+	rand.New(rand.NewSource(int64(p) + int64(seq))).Read(b)
+}
+
+// ParsePattern parses pattern definition, one of: fixed:<byte>, incrementing or random:<seed>, e.g. fixed:0xff.
+func ParsePattern(definition string) (Pattern, error) {
+	name, arg := definition, ""
+	if i := strings.Index(definition, ":"); i >= 0 {
+		name, arg = definition[:i], definition[i+1:]
+	}
+
+	switch name {
+	case "fixed":
+		v, err := strconv.ParseUint(arg, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte of fixed pattern %q", arg)
+		}
+		return FixedPattern(v), nil
+	case "incrementing":
+		return IncrementingPattern{}, nil
+	case "random":
+		seed, err := strconv.ParseInt(arg, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed of random pattern %q", arg)
+		}
+		return RandomPattern(seed), nil
+	}
+	return nil, fmt.Errorf("unknown payload pattern %q", definition)
+}
+
+// verify tells if payload is filled by pattern for sequence number seq.
+func verify(pattern Pattern, payload []byte, seq int) bool {
+	expected := make([]byte, len(payload))
+	pattern.Fill(expected, seq)
+	return bytes.Equal(payload, expected)
+}
@@ -0,0 +1,143 @@
+package goping
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestPattern(t *testing.T) {
+	cases := []struct {
+		Definition string
+		Expected   []byte
+	}{
+		{"fixed:0xff", []byte{0xff, 0xff, 0xff, 0xff}},
+		{"fixed:7", []byte{7, 7, 7, 7}},
+		{"incrementing", []byte{0, 1, 2, 3}},
+	}
+	for _, tc := range cases {
+		pattern, err := ParsePattern(tc.Definition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 4)
+		pattern.Fill(b, 1)
+		if !bytes.Equal(b, tc.Expected) {
+			t.Errorf("%s: expected %v, got: %v", tc.Definition, tc.Expected, b)
+		}
+	}
+
+	for _, definition := range []string{"fixed:256", "fixed", "random:seed", "zeros"} {
+		if _, err := ParsePattern(definition); err == nil {
+			t.Errorf("%s: invalid pattern accepted", definition)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pattern, _ := ParsePattern("random:42")
+	payload := make([]byte, 48)
+	pattern.Fill(payload, 3)
+
+	if !verify(pattern, payload, 3) {
+		t.Error("intact payload reported as corrupted")
+	}
+	if verify(pattern, payload, 4) {
+		t.Error("payload of other sequence accepted")
+	}
+	payload[10] ^= 0x04
+	if verify(pattern, payload, 3) {
+		t.Error("corrupted payload accepted")
+	}
+}
+
+func TestCorruptedReply(t *testing.T) {
+	p, err := NewPinger("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPrivileged(true)
+	p.Size = 40
+	p.Pattern = IncrementingPattern{}
+
+	reply := func(corrupt bool) *packet {
+		data := make([]byte, p.Size)
+		p.Pattern.Fill(data[HeaderLength:], 0)
+		payload{tracker: p.Tracker, sent: time.Now()}.marshal(data)
+		if corrupt {
+			data[28] ^= 0x10
+		}
+		msg := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: p.id, Seq: 0, Data: data}}
+		b, _ := msg.Marshal(nil)
+		return &packet{bytes: b, nbytes: len(b)}
+	}
+
+	var corrupted int
+	p.OnRecv = func(pkt *Packet) {
+		if pkt.Corrupted {
+			corrupted++
+		}
+	}
+	for _, corrupt := range []bool{false, true, true} {
+		if err := p.processPacket(reply(corrupt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.PacketsSent = 4
+
+	s := p.Statistics()
+	if s.PacketsRecv != 1 || s.PacketsCorrupted != 2 || corrupted != 2 {
+		t.Errorf("expected 1 intact and 2 corrupted replies, got: %d and %d", s.PacketsRecv, s.PacketsCorrupted)
+	}
+	if s.PacketLoss != 25 || s.PacketCorruption != 50 {
+		t.Errorf("expected 25%% loss and 50%% corruption, got: %v and %v", s.PacketLoss, s.PacketCorruption)
+	}
+}
+
+// replyConn returns the same datagram on each read.
+type replyConn struct {
+	net.PacketConn
+	datagram []byte
+}
+
+func (c *replyConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	return copy(b, c.datagram), &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, nil
+}
+
+func (c *replyConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func TestLargeReply(t *testing.T) {
+	p, err := NewPinger("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPrivileged(true)
+	p.Size = 1400
+	p.Count = 1
+
+	data := make([]byte, p.Size)
+	p.Pattern.Fill(data[HeaderLength:], 0)
+	payload{tracker: p.Tracker, sent: time.Now()}.marshal(data)
+	msg := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: p.id, Seq: 0, Data: data}}
+	b, _ := msg.Marshal(nil)
+
+	var wg sync.WaitGroup
+	recv := make(chan *packet, 1)
+	wg.Add(1)
+	go p.recvICMP(&replyConn{datagram: b}, recv, &wg)
+	wg.Wait()
+
+	if err := p.processPacket(<-recv); err != nil {
+		t.Fatal(err)
+	}
+	if p.PacketsRecv != 1 || p.PacketsCorrupted != 0 {
+		t.Errorf("expected intact reply of %d bytes, got: %d intact and %d corrupted", p.Size, p.PacketsRecv, p.PacketsCorrupted)
+	}
+}
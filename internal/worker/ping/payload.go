@@ -0,0 +1,45 @@
+package goping
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// payloadMagic marks echo requests sent by uping, "UPNG"
+	payloadMagic = 0x55504e47
+
+	// HeaderLength is the minimum size of echo payload: magic, tracker, sequence and send time,
+	// the rest of payload is filled by pattern.
+	HeaderLength = 24
+
+	// MaxLength is the maximum size of echo payload fitting into IPv4 datagram.
+	MaxLength = 65535 - 20 - 8
+)
+
+// payload is the header of echo request payload.
+type payload struct {
+	tracker int64
+	seq     uint32
+	sent    time.Time
+}
+
+// marshal writes header into the beginning of b, at least HeaderLength bytes long.
+func (p payload) marshal(b []byte) {
+	binary.BigEndian.PutUint32(b[0:4], payloadMagic)
+	binary.BigEndian.PutUint64(b[4:12], uint64(p.tracker))
+	binary.BigEndian.PutUint32(b[12:16], p.seq)
+	binary.BigEndian.PutUint64(b[16:24], uint64(p.sent.UnixNano()))
+}
+
+// parsePayload reads header of echo reply payload, false is returned if payload wasn't sent by uping.
+func parsePayload(b []byte) (payload, bool) {
+	if len(b) < HeaderLength || binary.BigEndian.Uint32(b[0:4]) != payloadMagic {
+		return payload{}, false
+	}
+	return payload{
+		tracker: int64(binary.BigEndian.Uint64(b[4:12])),
+		seq:     binary.BigEndian.Uint32(b[12:16]),
+		sent:    time.Unix(0, int64(binary.BigEndian.Uint64(b[16:24]))),
+	}, true
+}
@@ -0,0 +1,28 @@
+package goping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPayload(t *testing.T) {
+	sent := time.Unix(1600000000, 123456789)
+	b := make([]byte, HeaderLength+8)
+	payload{tracker: -42, seq: 70000, sent: sent}.marshal(b)
+
+	got, ok := parsePayload(b)
+	if !ok {
+		t.Fatal("payload not recognized")
+	}
+	if got.tracker != -42 || got.seq != 70000 || !got.sent.Equal(sent) {
+		t.Errorf("invalid payload, got: %+v", got)
+	}
+
+	if _, ok := parsePayload(b[:HeaderLength-1]); ok {
+		t.Error("too short payload accepted")
+	}
+	b[0] = 0
+	if _, ok := parsePayload(b); ok {
+		t.Error("payload without magic accepted")
+	}
+}
@@ -0,0 +1,23 @@
+package goping
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Sources of receive time used to compute round-trip times.
+const (
+	// TimestampSourceUser means packets are timestamped when read by pinger.
+	TimestampSourceUser = "user"
+
+	// TimestampSourceKernel means packets are timestamped by kernel on arrival.
+	TimestampSourceKernel = "kernel"
+)
+
+var errUnsupportedTimestamps = errors.New("kernel receive timestamps are supported only on Linux")
+
+// timestampedReader reads packets together with their kernel receive time.
+type timestampedReader interface {
+	ReadTimestamped(b []byte) (int, net.Addr, time.Time, error)
+}
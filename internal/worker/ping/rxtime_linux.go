@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package goping
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// timestampedConn is ICMP socket reporting kernel receive time of packets using SO_TIMESTAMPNS.
+type timestampedConn struct {
+	net.PacketConn
+	readMsg func(b, oob []byte) (int, int, net.Addr, error)
+	oob     []byte
+}
+
+// listenTimestamped opens ICMP socket of network (ip4:icmp, ip6:ipv6-icmp, udp4 or udp6) with kernel receive timestamps enabled.
+func listenTimestamped(network, address string) (net.PacketConn, error) {
+	var conn net.PacketConn
+	var err error
+	switch network {
+	case "udp4", "udp6":
+		conn, err = listenPingSocket(network, address)
+	default:
+		conn, err = net.ListenPacket(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newTimestampedConn(conn)
+}
+
+// newTimestampedConn enables kernel receive timestamps of conn, it's closed on failure.
+func newTimestampedConn(conn net.PacketConn) (net.PacketConn, error) {
+	var err error
+	c := &timestampedConn{PacketConn: conn, oob: make([]byte, 128)}
+	var raw syscall.RawConn
+	switch conn := conn.(type) {
+	case *net.IPConn:
+		raw, err = conn.SyscallConn()
+		c.readMsg = func(b, oob []byte) (int, int, net.Addr, error) {
+			n, oobn, _, addr, err := conn.ReadMsgIP(b, oob)
+			return n, oobn, addr, err
+		}
+	case *net.UDPConn:
+		raw, err = conn.SyscallConn()
+		c.readMsg = func(b, oob []byte) (int, int, net.Addr, error) {
+			n, oobn, _, addr, err := conn.ReadMsgUDP(b, oob)
+			return n, oobn, addr, err
+		}
+	default:
+		err = errUnsupportedTimestamps
+	}
+	if err == nil {
+		cerr := raw.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+		})
+		if cerr != nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// listenPingSocket opens unprivileged ICMP socket, the same way as icmp.ListenPacket does.
+func listenPingSocket(network, address string) (net.PacketConn, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	if network == "udp6" {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+	}
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	if ip := net.ParseIP(address); ip != nil {
+		var sa unix.Sockaddr
+		if family == unix.AF_INET {
+			sa4 := &unix.SockaddrInet4{}
+			copy(sa4.Addr[:], ip.To4())
+			sa = sa4
+		} else {
+			sa6 := &unix.SockaddrInet6{}
+			copy(sa6.Addr[:], ip.To16())
+			sa = sa6
+		}
+		if err := unix.Bind(fd, sa); err != nil {
+			unix.Close(fd)
+			return nil, os.NewSyscallError("bind", err)
+		}
+	}
+
+	f := os.NewFile(uintptr(fd), "datagram-oriented icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// ReadTimestamped reads packet and returns its source address and kernel receive time, or current time if kernel
+// didn't report it.
+func (c *timestampedConn) ReadTimestamped(b []byte) (int, net.Addr, time.Time, error) {
+	n, oobn, addr, err := c.readMsg(b, c.oob)
+	received := time.Now()
+	if err != nil {
+		return n, addr, received, err
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(c.oob[:oobn])
+	if err != nil {
+		return n, addr, received, nil
+	}
+	for _, m := range msgs {
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPNS && len(m.Data) >= int(unsafe.Sizeof(unix.Timespec{})) {
+			ts := (*unix.Timespec)(unsafe.Pointer(&m.Data[0]))
+			received = time.Unix(ts.Unix())
+		}
+	}
+	return n, addr, received, nil
+}
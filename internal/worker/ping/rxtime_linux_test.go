@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package goping
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestKernelTimestamps(t *testing.T) {
+	udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := newTimestampedConn(udp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sent := time.Now()
+	if _, err := conn.WriteTo([]byte("ping"), conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	b := make([]byte, 16)
+	n, _, received, err := conn.(timestampedReader).ReadTimestamped(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "ping" {
+		t.Errorf("invalid packet, got: %q", b[:n])
+	}
+	// packet was read 50ms after arrival, kernel timestamp tells when it arrived
+	if delay := received.Sub(sent); delay < 0 || delay > 25*time.Millisecond {
+		t.Errorf("expected kernel receive time, got: %v after send", delay)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package goping
+
+import "net"
+
+func listenTimestamped(network, address string) (net.PacketConn, error) {
+	return nil, errUnsupportedTimestamps
+}
@@ -0,0 +1,131 @@
+package goping
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+const (
+	timestampBodyLength = 16
+	// timestamps with high bit set are not milliseconds since midnight UT, RFC 792
+	nonStandardTimestamp = 1 << 31
+	day                  = 24 * time.Hour
+)
+
+// timestampRequestBody builds body of ICMP Timestamp Request with originate timestamp of t.
+func timestampRequestBody(id, seq int, t time.Time) []byte {
+	b := make([]byte, timestampBodyLength)
+	binary.BigEndian.PutUint16(b[0:2], uint16(id))
+	binary.BigEndian.PutUint16(b[2:4], uint16(seq))
+	binary.BigEndian.PutUint32(b[4:8], uint32(sinceMidnight(t)/time.Millisecond))
+	return b
+}
+
+// timestampReply is parsed ICMP Timestamp Reply.
+type timestampReply struct {
+	id, seq                      int
+	originate, receive, transmit uint32
+}
+
+func parseTimestampReply(b []byte) (timestampReply, error) {
+	if len(b) < timestampBodyLength {
+		return timestampReply{}, fmt.Errorf("Error, too short ICMP timestamp reply")
+	}
+	return timestampReply{
+		id:        int(binary.BigEndian.Uint16(b[0:2])),
+		seq:       int(binary.BigEndian.Uint16(b[2:4])),
+		originate: binary.BigEndian.Uint32(b[4:8]),
+		receive:   binary.BigEndian.Uint32(b[8:12]),
+		transmit:  binary.BigEndian.Uint32(b[12:16]),
+	}, nil
+}
+
+// delays estimates forward and reverse one-way delays and clock offset of remote host using reply to request sent
+// at t1 and received at t4. Delays include clock offset, e.g. if remote clock is ahead forward delay is overestimated
+// and reverse underestimated by the offset, but their changes still show which leg of path is affected.
+func (r timestampReply) delays(t1, t4 time.Time) (forward, reverse, offset time.Duration, ok bool) {
+	if r.receive&nonStandardTimestamp != 0 || r.transmit&nonStandardTimestamp != 0 {
+		return 0, 0, 0, false
+	}
+	t2 := time.Duration(r.receive) * time.Millisecond
+	t3 := time.Duration(r.transmit) * time.Millisecond
+
+	forward = wrapDay(t2 - sinceMidnight(t1))
+	reverse = wrapDay(sinceMidnight(t4) - t3)
+	offset = (forward - reverse) / 2
+	return forward, reverse, offset, true
+}
+
+// sinceMidnight returns time elapsed since midnight UT.
+func sinceMidnight(t time.Time) time.Duration {
+	t = t.UTC()
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// wrapDay normalizes difference of times since midnight crossing midnight into range of (-12h, 12h].
+func wrapDay(d time.Duration) time.Duration {
+	for d > day/2 {
+		d -= day
+	}
+	for d <= -day/2 {
+		d += day
+	}
+	return d
+}
+
+// timestampBody returns body of timestamp request and records its send time.
+func (p *Pinger) timestampBody() icmp.MessageBody {
+	now := time.Now()
+	if p.sent == nil {
+		p.sent = make(map[uint16]time.Time)
+	}
+	p.sent[uint16(p.sequence)] = now
+	return &icmp.RawBody{Data: timestampRequestBody(p.id, p.sequence, now)}
+}
+
+func (p *Pinger) processTimestampReply(m *icmp.Message, recv *packet) error {
+	body, ok := m.Body.(*icmp.RawBody)
+	if !ok {
+		return fmt.Errorf("Error, invalid ICMP timestamp reply. Body type: %T", m.Body)
+	}
+	reply, err := parseTimestampReply(body.Data)
+	if err != nil {
+		return err
+	}
+	if reply.id != p.id {
+		return nil
+	}
+	sent, ok := p.sent[uint16(reply.seq)]
+	if !ok {
+		// duplicated reply
+		return nil
+	}
+	delete(p.sent, uint16(reply.seq))
+
+	received := recv.received
+	outPkt := &Packet{
+		Rtt:    received.Sub(sent),
+		Nbytes: recv.nbytes,
+		IPAddr: p.ipaddr,
+		Addr:   p.addr,
+		Seq:    reply.seq,
+	}
+	if forward, reverse, offset, ok := reply.delays(sent, received); ok {
+		outPkt.OneWay = true
+		outPkt.Forward, outPkt.Reverse, outPkt.Offset = forward, reverse, offset
+		p.forwards = append(p.forwards, forward)
+		p.reverses = append(p.reverses, reverse)
+		p.offsets = append(p.offsets, offset)
+	}
+	p.PacketsRecv++
+
+	p.rtts = append(p.rtts, outPkt.Rtt)
+	handler := p.OnRecv
+	if handler != nil {
+		handler(outPkt)
+	}
+	return nil
+}
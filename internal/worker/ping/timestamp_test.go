@@ -0,0 +1,77 @@
+package goping
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestTimestampDelays(t *testing.T) {
+	t1 := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	body := timestampRequestBody(0x1234, 7, t1)
+
+	// remote clock is 5ms ahead, forward and reverse delays 10ms, processing 1ms
+	binary.BigEndian.PutUint32(body[8:12], uint32((10*time.Hour+15*time.Millisecond)/time.Millisecond))
+	binary.BigEndian.PutUint32(body[12:16], uint32((10*time.Hour+16*time.Millisecond)/time.Millisecond))
+	t4 := t1.Add(21 * time.Millisecond)
+
+	reply, err := parseTimestampReply(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.id != 0x1234 || reply.seq != 7 {
+		t.Errorf("invalid reply id/seq, got: %v/%v", reply.id, reply.seq)
+	}
+
+	forward, reverse, offset, ok := reply.delays(t1, t4)
+	if !ok {
+		t.Fatal("standard timestamps rejected")
+	}
+	// delays include offset, forward is overestimated and reverse underestimated by it
+	if forward != 15*time.Millisecond || reverse != 5*time.Millisecond || offset != 5*time.Millisecond {
+		t.Errorf("invalid delays, got: forward %v, reverse %v, offset %v", forward, reverse, offset)
+	}
+
+	if _, err := parseTimestampReply(body[:12]); err == nil {
+		t.Error("too short reply accepted")
+	}
+
+	binary.BigEndian.PutUint32(body[8:12], nonStandardTimestamp|1)
+	reply, _ = parseTimestampReply(body)
+	if _, _, _, ok := reply.delays(t1, t4); ok {
+		t.Error("non standard timestamp accepted")
+	}
+}
+
+func TestTimestampMidnight(t *testing.T) {
+	// request sent just before midnight, remote received it after midnight
+	t1 := time.Date(2020, 1, 1, 23, 59, 59, 995e6, time.UTC)
+	body := timestampRequestBody(1, 1, t1)
+	binary.BigEndian.PutUint32(body[8:12], 5)
+	binary.BigEndian.PutUint32(body[12:16], 6)
+
+	reply, _ := parseTimestampReply(body)
+	forward, reverse, _, ok := reply.delays(t1, t1.Add(20*time.Millisecond))
+	if !ok || forward != 10*time.Millisecond || reverse != 9*time.Millisecond {
+		t.Errorf("invalid delays over midnight, got: forward %v, reverse %v", forward, reverse)
+	}
+}
+
+func TestTimestampUnsupported(t *testing.T) {
+	pinger, err := NewPinger("::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats *Statistics
+	pinger.OnFinish = func(s *Statistics) {
+		stats = s
+	}
+	pinger.SetPrivileged(true)
+	pinger.Timestamp = true
+	pinger.Run()
+
+	if stats == nil || stats.Error == nil || stats.PacketLoss != 100 {
+		t.Errorf("expected statistics with error of IPv6 timestamp ping, got: %v", stats)
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/radiusprobe"
+)
+
+// RADIUS worker iterates over schema.Host tasks, authenticating test user on each of them and push results into
+// config.Results channel, rejected test user or invalid response is reported as CRITICAL status.
+func RADIUS(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := radiusprobe.NewRADIUSProbe(device.IP, device.Port)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *radiusprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				if stats.Error == radiusprobe.ErrInvalidAuthenticator {
+					result.Status = schema.StatusCritical
+				}
+				result.Output = append(result.Output, fmt.Sprintf("RADIUS request to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			result.RADIUS = &schema.RADIUSResult{Response: stats.Response, ReplyMessage: stats.ReplyMessage}
+			if stats.Response != radiusprobe.ResponseAccept {
+				result.Status = schema.StatusCritical
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s radius statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d requests transmitted, %d responses received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("round-trip min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			line += fmt.Sprintf("response: %s", stats.Response)
+			if stats.ReplyMessage != "" {
+				line += fmt.Sprintf(", %s", stats.ReplyMessage)
+			}
+			line += "\n"
+			result.Output = append(result.Output, line)
+		}
+
+		probe.User = config.Probe.RADIUS.User
+		probe.Password = config.Probe.RADIUS.Password
+		probe.Secret = config.Probe.RADIUS.Secret
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
@@ -0,0 +1,316 @@
+package radiusprobe
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+// RADIUS codes and attributes used by probe, RFC 2865 and RFC 3579.
+const (
+	codeAccessRequest   = 1
+	codeAccessAccept    = 2
+	codeAccessReject    = 3
+	codeAccessChallenge = 11
+
+	attrUserName             = 1
+	attrUserPassword         = 2
+	attrReplyMessage         = 18
+	attrNASIdentifier        = 32
+	attrMessageAuthenticator = 80
+
+	headerSize = 20
+	maxSize    = 4096
+)
+
+// Responses of RADIUS server.
+const (
+	ResponseAccept    = "ACCEPT"
+	ResponseReject    = "REJECT"
+	ResponseChallenge = "CHALLENGE"
+)
+
+// ErrInvalidAuthenticator is returned if Response Authenticator of response doesn't match, e.g. due to wrong shared secret.
+var ErrInvalidAuthenticator = errors.New("invalid Response Authenticator, check shared secret")
+
+// RADIUSProbe sends Access-Request with test credentials to RADIUS server and validates its response.
+type RADIUSProbe struct {
+	ip   string
+	port string
+
+	// Secret is shared secret of client and server.
+	Secret string
+
+	// User is test user name.
+	User string
+
+	// Password is test user password.
+	Password string
+
+	// NASIdentifier is NAS-Identifier sent in request, default is "uping".
+	NASIdentifier string
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	rtts          []time.Duration
+	response      string
+	replyMessage  string
+	err           error
+
+	// OnFinish is called when RADIUSProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Statistics represent the stats of a RADIUSProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is RADIUS authentication port.
+	Port string
+
+	// RequestsSent is the number of requests sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of valid responses received.
+	ResponsesRecv int
+
+	// Loss is the percentage of requests without valid response.
+	Loss float64
+
+	// MinRtt is the minimum response time.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum response time.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average response time.
+	AvgRtt time.Duration
+
+	// Response is the last response of server, ACCEPT, REJECT or CHALLENGE.
+	Response string
+
+	// ReplyMessage is Reply-Message attribute of the last response.
+	ReplyMessage string
+
+	// Error specifies the last request error, e.g. timeout or invalid Response Authenticator.
+	Error error
+}
+
+// NewRADIUSProbe returns a new RADIUSProbe struct pointer.
+func NewRADIUSProbe(host, port string) (*RADIUSProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	return &RADIUSProbe{
+		ip:            ip.String(),
+		port:          port,
+		NASIdentifier: "uping",
+		Count:         1,
+		Interval:      time.Second,
+		Timeout:       time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *RADIUSProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *RADIUSProbe) run() {
+	conn, err := net.Dial("udp", net.JoinHostPort(p.ip, p.port))
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		// identifier distinguishes requests sent over the same socket
+		id := byte(p.requestsSent)
+		p.requestsSent++
+		rtt, err := p.authenticate(conn, id)
+		if err != nil {
+			p.err = err
+			continue
+		}
+		p.rtts = append(p.rtts, rtt)
+		p.responsesRecv++
+	}
+}
+
+// authenticate sends one Access-Request and validates response.
+func (p *RADIUSProbe) authenticate(conn net.Conn, id byte) (time.Duration, error) {
+	authenticator := make([]byte, 16)
+	if _, err := rand.Read(authenticator); err != nil {
+		return 0, err
+	}
+	request := p.accessRequest(id, authenticator)
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(p.Timeout))
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, maxSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+
+		response := buf[:n]
+		if n < headerSize || response[1] != id {
+			// late response to previous request
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(response[2:4]))
+		if length < headerSize || length > n {
+			return 0, fmt.Errorf("malformed response")
+		}
+		response = response[:length]
+
+		if !bytes.Equal(response[4:20], p.responseAuthenticator(response, authenticator)) {
+			return 0, ErrInvalidAuthenticator
+		}
+
+		switch response[0] {
+		case codeAccessAccept:
+			p.response = ResponseAccept
+		case codeAccessReject:
+			p.response = ResponseReject
+		case codeAccessChallenge:
+			p.response = ResponseChallenge
+		default:
+			return 0, fmt.Errorf("unexpected response code %d", response[0])
+		}
+		p.replyMessage = string(attribute(response[headerSize:], attrReplyMessage))
+		return rtt, nil
+	}
+}
+
+// accessRequest builds Access-Request packet with hidden User-Password and Message-Authenticator.
+func (p *RADIUSProbe) accessRequest(id byte, authenticator []byte) []byte {
+	packet := []byte{codeAccessRequest, id, 0, 0}
+	packet = append(packet, authenticator...)
+	packet = appendAttribute(packet, attrUserName, []byte(p.User))
+	packet = appendAttribute(packet, attrUserPassword, p.hidePassword(authenticator))
+	packet = appendAttribute(packet, attrNASIdentifier, []byte(p.NASIdentifier))
+
+	// Message-Authenticator is HMAC-MD5 of whole packet with zeroed attribute value
+	packet = appendAttribute(packet, attrMessageAuthenticator, make([]byte, md5.Size))
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	mac := hmac.New(md5.New, []byte(p.Secret))
+	mac.Write(packet)
+	copy(packet[len(packet)-md5.Size:], mac.Sum(nil))
+	return packet
+}
+
+// hidePassword hides User-Password as described in RFC 2865 section 5.2.
+func (p *RADIUSProbe) hidePassword(authenticator []byte) []byte {
+	password := []byte(p.Password)
+	if len(password) == 0 || len(password)%16 != 0 {
+		password = append(password, make([]byte, 16-len(password)%16)...)
+	}
+
+	hidden := make([]byte, len(password))
+	previous := authenticator
+	for i := 0; i < len(password); i += 16 {
+		hash := md5.Sum(append([]byte(p.Secret), previous...))
+		for j := 0; j < 16; j++ {
+			hidden[i+j] = password[i+j] ^ hash[j]
+		}
+		previous = hidden[i : i+16]
+	}
+	return hidden
+}
+
+// responseAuthenticator returns expected Response Authenticator of response to request with authenticator.
+func (p *RADIUSProbe) responseAuthenticator(response, authenticator []byte) []byte {
+	hash := md5.New()
+	hash.Write(response[:4])
+	hash.Write(authenticator)
+	hash.Write(response[headerSize:])
+	hash.Write([]byte(p.Secret))
+	return hash.Sum(nil)
+}
+
+func appendAttribute(packet []byte, attr byte, value []byte) []byte {
+	if len(value) > 253 {
+		value = value[:253]
+	}
+	packet = append(packet, attr, byte(len(value)+2))
+	return append(packet, value...)
+}
+
+// attribute returns value of the first attribute attr.
+func attribute(attrs []byte, attr byte) []byte {
+	for len(attrs) >= 2 {
+		length := int(attrs[1])
+		if length < 2 || length > len(attrs) {
+			return nil
+		}
+		if attrs[0] == attr {
+			return attrs[2:length]
+		}
+		attrs = attrs[length:]
+	}
+	return nil
+}
+
+// Statistics returns the statistics of the RADIUSProbe.
+func (p *RADIUSProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          p.port,
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Response:      p.response,
+		ReplyMessage:  p.replyMessage,
+		Error:         p.err,
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(p.rtts)
+	return &s
+}
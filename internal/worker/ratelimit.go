@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/migotom/uberping/internal/schema"
+	goping "github.com/migotom/uberping/internal/worker/ping"
+)
+
+// rateLimited tells if loss depends on send rate, i.e. burst loss is at least threshold percentage points higher
+// than loss of spaced train which reached host.
+func rateLimited(burstLoss, trainLoss, threshold float64) bool {
+	return trainLoss < 100 && burstLoss-trainLoss >= threshold
+}
+
+// train pings ip sending count echo requests every interval.
+func train(config schema.GeneralConfig, ip string, count int, interval time.Duration, pace func()) (*goping.Statistics, error) {
+	pinger, err := goping.NewPinger(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats *goping.Statistics
+	pinger.OnFinish = func(s *goping.Statistics) {
+		stats = s
+	}
+	pinger.SetPrivileged(config.Probe.Privileged)
+	pinger.Interval = interval
+	pinger.Pace = pace
+	pinger.Count = count
+	pinger.Timeout = config.Probe.Timeout.Duration
+	pinger.Run()
+	if stats == nil {
+		return nil, fmt.Errorf("ping of %s didn't finish", ip)
+	}
+	if stats.Error != nil {
+		return nil, stats.Error
+	}
+	return stats, nil
+}
+
+// RateLimit worker iterates over schema.Host tasks, pinging each of them by spaced train and short burst of echo
+// requests, host losing clearly more of burst is reported as ICMP rate-limited with loss of spaced train.
+func RateLimit(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for device := range jobs {
+		var result schema.ProbeResult
+
+		if !resolve(config, &device) {
+			continue
+		}
+
+		// spaced train goes first, so it isn't affected by limiter drained by burst, burst is sent unpaced on purpose
+		spaced, err := train(config, device.IP, config.Probe.Count, config.Probe.Interval.Duration, pace(config, device.IP))
+		if err != nil {
+			unprobed(config, device, err)
+			continue
+		}
+		burst, err := train(config, device.IP, config.Probe.RateLimit.Burst, config.Probe.RateLimit.BurstInterval.Duration, nil)
+		if err != nil {
+			unprobed(config, device, err)
+			continue
+		}
+
+		result.RateLimit = &schema.RateLimitResult{
+			BurstLoss: burst.PacketLoss,
+			TrainLoss: spaced.PacketLoss,
+			Limited:   rateLimited(burst.PacketLoss, spaced.PacketLoss, config.Probe.RateLimit.Threshold),
+		}
+
+		var line string
+		line += fmt.Sprintf("\n--- %s rate limit statistics ---\n", spaced.Addr)
+		line += fmt.Sprintf("spaced train: %d packets transmitted every %v, %d packets received, %v%% packet loss\n",
+			spaced.PacketsSent, config.Probe.Interval.Duration, spaced.PacketsRecv, spaced.PacketLoss)
+		line += fmt.Sprintf("burst: %d packets transmitted every %v, %d packets received, %v%% packet loss\n",
+			burst.PacketsSent, config.Probe.RateLimit.BurstInterval.Duration, burst.PacketsRecv, burst.PacketLoss)
+		if result.RateLimit.Limited {
+			result.Status = schema.StatusRateLimited
+			line += "ICMP rate-limited, loss depends on send rate\n"
+		}
+		line += fmt.Sprintf("round-trip min/avg/max/stddev = %v/%v/%v/%v\n",
+			toMs(spaced.MinRtt), toMs(spaced.AvgRtt), toMs(spaced.MaxRtt), toMs(spaced.StdDevRtt))
+
+		result.Output = append(result.Output, line)
+		result.Loss = spaced.PacketLoss
+		result.AvgTime = spaced.AvgRtt.Seconds()
+		result.Host = device
+		annotate(config, &result)
+		config.Results <- result
+	}
+}
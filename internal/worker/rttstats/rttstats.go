@@ -0,0 +1,23 @@
+package rttstats
+
+import "time"
+
+// MinAvgMax returns the minimum, average and maximum of response times, or zeros if there are none.
+func MinAvgMax(rtts []time.Duration) (min, avg, max time.Duration) {
+	if len(rtts) == 0 {
+		return 0, 0, 0
+	}
+
+	var total time.Duration
+	min, max = rtts[0], rtts[0]
+	for _, rtt := range rtts {
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+		total += rtt
+	}
+	return min, total / time.Duration(len(rtts)), max
+}
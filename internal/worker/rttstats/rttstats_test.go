@@ -0,0 +1,23 @@
+package rttstats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinAvgMax(t *testing.T) {
+	cases := []struct {
+		rtts          []time.Duration
+		min, avg, max time.Duration
+	}{
+		{nil, 0, 0, 0},
+		{[]time.Duration{5 * time.Millisecond}, 5 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
+		{[]time.Duration{3 * time.Millisecond, time.Millisecond, 8 * time.Millisecond}, time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond},
+	}
+	for _, c := range cases {
+		min, avg, max := MinAvgMax(c.rtts)
+		if min != c.min || avg != c.avg || max != c.max {
+			t.Errorf("response times %v expected min/avg/max %v/%v/%v, got: %v/%v/%v", c.rtts, c.min, c.avg, c.max, min, avg, max)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/snmpprobe"
+)
+
+// SNMP worker iterates over schema.Host tasks, sending SNMP GET requests to each of them and push results into
+// config.Results channel, device reboot is detected by sysUpTime lower than recorded in previous round.
+func SNMP(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := snmpprobe.NewSNMPProbe(device.IP, device.Port)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *snmpprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.ResponsesRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("SNMP request to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			snmp := &schema.SNMPResult{SysName: stats.Value(snmpprobe.OIDSysName), Values: make(map[string]string)}
+			for _, v := range stats.Variables {
+				snmp.Values[v.OID] = v.Value
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s snmp statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d requests transmitted, %d responses received, %v%% loss\n",
+				stats.RequestsSent, stats.ResponsesRecv, stats.Loss)
+			line += fmt.Sprintf("round-trip min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			if snmp.SysName != "" {
+				line += fmt.Sprintf("sysName: %s\n", snmp.SysName)
+			}
+
+			if uptime, ok := stats.Uptime(); ok {
+				snmp.Uptime = uptime.Seconds()
+				line += fmt.Sprintf("sysUpTime: %v\n", uptime)
+
+				if config.Probe.SNMP.Uptimes != nil {
+					if previous, rebooted := config.Probe.SNMP.Uptimes.Update(device.IP, uptime); rebooted {
+						snmp.Rebooted = true
+						result.Status = schema.StatusRebooted
+						line += fmt.Sprintf("Device %s rebooted, sysUpTime went back from %v to %v!\n", stats.Addr, previous, uptime)
+					}
+				}
+			}
+
+			result.SNMP = snmp
+			result.Output = append(result.Output, line)
+		}
+
+		if config.Probe.SNMP.Version != "" {
+			probe.Version = config.Probe.SNMP.Version
+		}
+		if config.Probe.SNMP.Community != "" {
+			probe.Community = config.Probe.SNMP.Community
+		}
+		if len(config.Probe.SNMP.OIDs) > 0 {
+			probe.OIDs = config.Probe.SNMP.OIDs
+		}
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
@@ -0,0 +1,282 @@
+package snmpprobe
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+// Default OIDs requested from device.
+const (
+	OIDSysUpTime = ".1.3.6.1.2.1.1.3.0"
+	OIDSysName   = ".1.3.6.1.2.1.1.5.0"
+)
+
+// SNMPProbe sends SNMP GET requests to device and measures their response time.
+type SNMPProbe struct {
+	ip   string
+	port uint16
+
+	// Community is SNMP community string, default is "public".
+	Community string
+
+	// Version is SNMP version, "1" or "2c" (default).
+	Version string
+
+	// OIDs specifies OIDs requested from device, default is sysUpTime and sysName.
+	OIDs []string
+
+	// Count tells probe to stop after sending Count requests.
+	Count int
+
+	// Interval is the wait time between each request.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each request.
+	Timeout time.Duration
+
+	requestsSent  int
+	responsesRecv int
+	rtts          []time.Duration
+	variables     []Variable
+	err           error
+
+	// OnFinish is called when SNMPProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Variable is one OID value returned by device.
+type Variable struct {
+	// OID is name of variable.
+	OID string
+
+	// Value is variable value formatted as string.
+	Value string
+
+	// Uptime is variable value of TimeTicks type, e.g. sysUpTime.
+	Uptime time.Duration
+}
+
+// Statistics represent the stats of a SNMPProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is SNMP agent port.
+	Port string
+
+	// RequestsSent is the number of requests sent.
+	RequestsSent int
+
+	// ResponsesRecv is the number of responses received.
+	ResponsesRecv int
+
+	// Loss is the percentage of requests lost.
+	Loss float64
+
+	// MinRtt is the minimum response time.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum response time.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average response time.
+	AvgRtt time.Duration
+
+	// Variables are values returned by the last response.
+	Variables []Variable
+
+	// Error specifies the last request error.
+	Error error
+}
+
+// Uptime returns value of sysUpTime variable and true if device returned it.
+func (s *Statistics) Uptime() (time.Duration, bool) {
+	for _, v := range s.Variables {
+		if v.OID == OIDSysUpTime {
+			return v.Uptime, true
+		}
+	}
+	return 0, false
+}
+
+// Value returns value of variable oid.
+func (s *Statistics) Value(oid string) string {
+	for _, v := range s.Variables {
+		if v.OID == oid {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// NewSNMPProbe returns a new SNMPProbe struct pointer.
+func NewSNMPProbe(host, port string) (*SNMPProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	return &SNMPProbe{
+		ip:        ip.String(),
+		port:      uint16(p),
+		Community: "public",
+		Version:   "2c",
+		OIDs:      []string{OIDSysUpTime, OIDSysName},
+		Count:     1,
+		Interval:  time.Second,
+		Timeout:   time.Second,
+	}, nil
+}
+
+// Run sends requests, this is a blocking function that will exit when it's done.
+func (p *SNMPProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *SNMPProbe) run() {
+	client := &gosnmp.GoSNMP{
+		Target:    p.ip,
+		Port:      p.port,
+		Community: p.Community,
+		Timeout:   p.Timeout,
+		Retries:   0,
+	}
+	switch p.Version {
+	case "1":
+		client.Version = gosnmp.Version1
+	case "2c", "":
+		client.Version = gosnmp.Version2c
+	default:
+		p.err = fmt.Errorf("unsupported SNMP version %s", p.Version)
+		return
+	}
+
+	if err := client.Connect(); err != nil {
+		p.err = err
+		return
+	}
+	defer client.Conn.Close()
+
+	for p.requestsSent < p.Count {
+		if p.requestsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		start := time.Now()
+		p.requestsSent++
+		packet, err := client.Get(p.OIDs)
+		if err != nil {
+			p.err = err
+			continue
+		}
+		if packet.Error != gosnmp.NoError {
+			p.err = fmt.Errorf("agent returned error %v at index %d", packet.Error, packet.ErrorIndex)
+			continue
+		}
+
+		p.rtts = append(p.rtts, time.Since(start))
+		p.responsesRecv++
+		p.variables = variables(packet.Variables)
+	}
+}
+
+// variables converts PDUs to variables, skipping OIDs not found by agent.
+func variables(pdus []gosnmp.SnmpPDU) []Variable {
+	var vars []Variable
+	for _, pdu := range pdus {
+		v := Variable{OID: pdu.Name}
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			continue
+		case gosnmp.OctetString:
+			v.Value = string(pdu.Value.([]byte))
+		case gosnmp.TimeTicks:
+			// TimeTicks are hundredths of a second
+			v.Uptime = time.Duration(gosnmp.ToBigInt(pdu.Value).Int64()) * 10 * time.Millisecond
+			v.Value = v.Uptime.String()
+		default:
+			v.Value = fmt.Sprint(pdu.Value)
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// Statistics returns the statistics of the SNMPProbe.
+func (p *SNMPProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          strconv.Itoa(int(p.port)),
+		RequestsSent:  p.requestsSent,
+		ResponsesRecv: p.responsesRecv,
+		Variables:     p.variables,
+		Error:         p.err,
+	}
+	if p.requestsSent > 0 {
+		s.Loss = float64(p.requestsSent-p.responsesRecv) / float64(p.requestsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(p.rtts)
+	return &s
+}
+
+// maxUptime is the value at which 32-bit sysUpTime counter of hundredths of second wraps, after about 497 days.
+const maxUptime = (1 << 32) * 10 * time.Millisecond
+
+// wrapSlack is tolerance of counter wrap detection, covering difference of device and local clocks.
+const wrapSlack = time.Minute
+
+// Uptimes keeps last sysUpTime of devices to detect their reboots between probing rounds, it's safe for concurrent use.
+type Uptimes struct {
+	mu      sync.Mutex
+	uptimes map[string]uptime
+}
+
+type uptime struct {
+	value time.Duration
+	seen  time.Time
+}
+
+// NewUptimes returns a new Uptimes struct pointer.
+func NewUptimes() *Uptimes {
+	return &Uptimes{uptimes: make(map[string]uptime)}
+}
+
+// Update records uptime of host and returns previously recorded one, rebooted is true if uptime went backwards,
+// unless counter could reach maxUptime since previous record and wrapped.
+func (u *Uptimes) Update(host string, value time.Duration) (time.Duration, bool) {
+	return u.update(host, value, time.Now())
+}
+
+func (u *Uptimes) update(host string, value time.Duration, now time.Time) (time.Duration, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	previous, ok := u.uptimes[host]
+	u.uptimes[host] = uptime{value: value, seen: now}
+	if !ok || value >= previous.value {
+		return previous.value, false
+	}
+
+	wrapped := previous.value+now.Sub(previous.seen)+wrapSlack >= maxUptime
+	return previous.value, !wrapped
+}
@@ -0,0 +1,31 @@
+package snmpprobe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUptimes(t *testing.T) {
+	u := NewUptimes()
+	now := time.Now()
+
+	cases := []struct {
+		uptime   time.Duration
+		after    time.Duration
+		rebooted bool
+	}{
+		{maxUptime - 10*time.Minute, 0, false},
+		{maxUptime - 5*time.Minute, 5 * time.Minute, false},
+		// counter wrapped
+		{5 * time.Minute, 10 * time.Minute, false},
+		{10 * time.Minute, 5 * time.Minute, false},
+		// rebooted
+		{time.Minute, 5 * time.Minute, true},
+	}
+	for i, c := range cases {
+		now = now.Add(c.after)
+		if _, rebooted := u.update("192.0.2.1", c.uptime, now); rebooted != c.rebooted {
+			t.Errorf("update %d of uptime %v expected rebooted %v, got: %v", i, c.uptime, c.rebooted, rebooted)
+		}
+	}
+}
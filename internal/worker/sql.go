@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/sqlprobe"
+)
+
+// SQL worker iterates over schema.Host tasks, connecting to database of each of them using DSN template and running
+// test query, results are pushed into config.Results channel.
+func SQL(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		var dsn strings.Builder
+		if err := config.Probe.SQL.Template.Execute(&dsn, device); err != nil {
+			return err
+		}
+
+		probe := sqlprobe.NewSQLProbe(config.Probe.SQL.Driver, dsn.String())
+		probe.OnFinish = func(stats *sqlprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = (stats.ConnectTime + stats.QueryTime).Seconds()
+
+			if stats.Successes == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("Database %s:%s query failed, %v!\n", device.IP, device.Port, stats.Error))
+				return
+			}
+
+			result.SQL = &schema.SQLResult{ConnectTime: stats.ConnectTime.Seconds(), QueryTime: stats.QueryTime.Seconds()}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s:%s database statistics ---\n", device.IP, device.Port)
+			line += fmt.Sprintf("%d attempts, %d succeeded, %v%% loss\n", stats.Attempts, stats.Successes, stats.Loss)
+			line += fmt.Sprintf("connect time=%v, query time=%v\n", toMs(stats.ConnectTime), toMs(stats.QueryTime))
+			if stats.Error != nil {
+				line += fmt.Sprintf("last error: %v\n", stats.Error)
+			}
+			result.Output = append(result.Output, line)
+		}
+
+		if config.Probe.SQL.Query != "" {
+			probe.Query = config.Probe.SQL.Query
+		}
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
@@ -0,0 +1,149 @@
+package sqlprobe
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/lib/pq" // load psql driver
+	"github.com/migotom/uberping/internal/worker/rttstats"
+)
+
+// SQLProbe connects to database and runs test query measuring connect and query time.
+type SQLProbe struct {
+	driver string
+	dsn    string
+
+	// Query is lightweight query run after connecting, default is "SELECT 1".
+	Query string
+
+	// Count tells probe to stop after Count attempts.
+	Count int
+
+	// Interval is the wait time between each attempt.
+	Interval time.Duration
+
+	// Timeout specifies timeout of each attempt.
+	Timeout time.Duration
+
+	attempts     int
+	successes    int
+	connectTimes []time.Duration
+	queryTimes   []time.Duration
+	err          error
+
+	// OnFinish is called when SQLProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Statistics represent the stats of a SQLProbe
+type Statistics struct {
+	// Attempts is the number of connection attempts.
+	Attempts int
+
+	// Successes is the number of attempts with successfully run query.
+	Successes int
+
+	// Loss is the percentage of failed attempts.
+	Loss float64
+
+	// ConnectTime is the average time of establishing connection.
+	ConnectTime time.Duration
+
+	// QueryTime is the average time of running query and reading its result.
+	QueryTime time.Duration
+
+	// Error specifies the last attempt error.
+	Error error
+}
+
+// NewSQLProbe returns a new SQLProbe struct pointer, driver is database/sql driver name, e.g. "postgres".
+func NewSQLProbe(driver, dsn string) *SQLProbe {
+	return &SQLProbe{
+		driver:   driver,
+		dsn:      dsn,
+		Query:    "SELECT 1",
+		Count:    1,
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}
+}
+
+// Run connects to database, this is a blocking function that will exit when it's done.
+func (p *SQLProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *SQLProbe) run() {
+	for p.attempts < p.Count {
+		if p.attempts > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		p.attempts++
+		if err := p.attempt(); err != nil {
+			p.err = err
+			continue
+		}
+		p.successes++
+	}
+}
+
+// attempt opens new connection and runs query on it.
+func (p *SQLProbe) attempt() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	db, err := sql.Open(p.driver, p.dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	start := time.Now()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	connectTime := time.Since(start)
+
+	start = time.Now()
+	rows, err := conn.QueryContext(ctx, p.Query)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	p.connectTimes = append(p.connectTimes, connectTime)
+	p.queryTimes = append(p.queryTimes, time.Since(start))
+	return nil
+}
+
+// Statistics returns the statistics of the SQLProbe.
+func (p *SQLProbe) Statistics() *Statistics {
+	s := Statistics{
+		Attempts:  p.attempts,
+		Successes: p.successes,
+		Error:     p.err,
+	}
+	_, s.ConnectTime, _ = rttstats.MinAvgMax(p.connectTimes)
+	_, s.QueryTime, _ = rttstats.MinAvgMax(p.queryTimes)
+	if p.attempts > 0 {
+		s.Loss = float64(p.attempts-p.successes) / float64(p.attempts) * 100
+	} else {
+		s.Loss = 100
+	}
+	return &s
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/sshprobe"
+)
+
+// SSH worker iterates over schema.Host tasks, performing SSH handshake with each of them and verifying host key
+// using fingerprint from host label ssh_fingerprint or known hosts file, mismatched key is reported as CRITICAL.
+func SSH(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := sshprobe.NewSSHProbe(device.IP, device.Port, device.Hostname)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *sshprobe.Statistics) {
+			if !stats.Connected {
+				result.Loss = 100
+				result.Output = append(result.Output, fmt.Sprintf("SSH handshake with %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			result.AvgTime = stats.HandshakeTime.Seconds()
+			result.SSH = &schema.SSHResult{
+				ServerVersion: stats.ServerVersion,
+				KeyType:       stats.KeyType,
+				Fingerprint:   stats.Fingerprint,
+				KeyVerified:   stats.KeyVerified,
+				HandshakeTime: stats.HandshakeTime.Seconds(),
+			}
+			result.Output = append(result.Output, fmt.Sprintf("SSH handshake with %s:%s succeded, time=%v, version: %s, key: %s %s!\n",
+				stats.Addr, stats.Port, toMs(stats.HandshakeTime), stats.ServerVersion, stats.KeyType, stats.Fingerprint))
+
+			if stats.KeyMismatch {
+				result.Status = schema.StatusCritical
+				result.Output = append(result.Output, fmt.Sprintf("Host key verification of %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.KeyError))
+			} else if stats.KeyError != nil {
+				result.Status = schema.StatusWarning
+				result.Output = append(result.Output, fmt.Sprintf("Host key of %s:%s can't be verified, %v!\n", stats.Addr, stats.Port, stats.KeyError))
+			}
+		}
+
+		probe.Timeout = config.Probe.Timeout.Duration
+		probe.ExpectedFingerprint = device.Labels["ssh_fingerprint"]
+		probe.KnownHosts = config.Probe.SSH.HostKeyCallback
+		if config.Probe.SSH.User != "" {
+			probe.User = config.Probe.SSH.User
+		}
+
+		probe.Run()
+		return nil
+	})
+}
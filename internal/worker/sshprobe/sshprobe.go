@@ -0,0 +1,232 @@
+package sshprobe
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHProbe performs SSH transport handshake with host without authenticating and verifies its host key.
+type SSHProbe struct {
+	ipaddr   *net.IPAddr
+	ip       string
+	port     string
+	hostname string
+
+	// Timeout specifies timeout of connection and handshake.
+	Timeout time.Duration
+
+	// User is name sent with authentication request ending handshake, default is "uping".
+	User string
+
+	// ExpectedFingerprint is SHA256 fingerprint of expected host key, e.g. "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8".
+	ExpectedFingerprint string
+
+	// KnownHosts verifies host key if no ExpectedFingerprint specified, e.g. created by knownhosts.New.
+	KnownHosts ssh.HostKeyCallback
+
+	handshakeTime time.Duration
+	serverVersion string
+	key           ssh.PublicKey
+	err           error
+
+	// OnFinish is called when SSHProbe exits
+	OnFinish func(*Statistics)
+}
+
+// Statistics represent the stats of a SSHProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is SSH service port.
+	Port string
+
+	// Connected specifies if handshake succeeded.
+	Connected bool
+
+	// HandshakeTime is time elapsed between dialing and receiving server host key.
+	HandshakeTime time.Duration
+
+	// ServerVersion is identification string sent by server, e.g. "SSH-2.0-OpenSSH_8.9".
+	ServerVersion string
+
+	// KeyType is type of server host key, e.g. "ssh-ed25519".
+	KeyType string
+
+	// Fingerprint is SHA256 fingerprint of server host key.
+	Fingerprint string
+
+	// KeyVerified specifies if host key was successfully verified by expected fingerprint or known hosts.
+	KeyVerified bool
+
+	// KeyMismatch specifies if host key differs from expected one.
+	KeyMismatch bool
+
+	// KeyError specifies host key verification failure.
+	KeyError error
+
+	// Error specifies connection or handshake error.
+	Error error
+}
+
+// versionConn records SSH identification string read from server.
+type versionConn struct {
+	net.Conn
+	buf     []byte
+	version string
+}
+
+func (c *versionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.version != "" {
+		return n, err
+	}
+
+	// identification string may be preceded by other lines, RFC 4253 section 4.2
+	c.buf = append(c.buf, b[:n]...)
+	for {
+		i := bytes.IndexByte(c.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(c.buf[:i]), "\r")
+		c.buf = c.buf[i+1:]
+		if strings.HasPrefix(line, "SSH-") {
+			c.version = line
+			c.buf = nil
+			break
+		}
+	}
+	return n, err
+}
+
+// NewSSHProbe returns a new SSHProbe struct pointer, hostname is used to find host in known hosts if specified.
+func NewSSHProbe(host, port, hostname string) (*SSHProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	if hostname == "" {
+		hostname = ip.String()
+	}
+
+	return &SSHProbe{
+		ipaddr:   ip,
+		ip:       ip.String(),
+		port:     port,
+		hostname: hostname,
+		User:     "uping",
+	}, nil
+}
+
+// Run performs handshake, this is a blocking function that will exit when it's done.
+func (p *SSHProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *SSHProbe) run() {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.ip, p.port), p.Timeout)
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(start.Add(p.Timeout))
+
+	vconn := &versionConn{Conn: conn}
+	config := &ssh.ClientConfig{
+		User: p.User,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			p.handshakeTime = time.Since(start)
+			p.key = key
+			return nil
+		},
+		Timeout: p.Timeout,
+	}
+
+	client, _, _, err := ssh.NewClientConn(vconn, net.JoinHostPort(p.hostname, p.port), config)
+	p.serverVersion = vconn.version
+	if client != nil {
+		// server accepted none authentication
+		client.Close()
+	}
+	if p.key == nil {
+		if err == nil {
+			err = fmt.Errorf("no host key received")
+		}
+		p.err = err
+	}
+}
+
+// verify checks host key using expected fingerprint or known hosts.
+func (p *SSHProbe) verify() (bool, bool, error) {
+	if p.key == nil {
+		return false, false, nil
+	}
+
+	if p.ExpectedFingerprint != "" {
+		if fingerprint := ssh.FingerprintSHA256(p.key); fingerprint != p.ExpectedFingerprint {
+			return false, true, fmt.Errorf("host key %s doesn't match expected %s", fingerprint, p.ExpectedFingerprint)
+		}
+		return true, false, nil
+	}
+
+	if p.KnownHosts != nil {
+		remote := &net.TCPAddr{IP: p.ipaddr.IP, Zone: p.ipaddr.Zone}
+		remote.Port, _ = strconv.Atoi(p.port)
+
+		err := p.KnownHosts(knownhosts.Normalize(net.JoinHostPort(p.hostname, p.port)), remote, p.key)
+		if keyErr, ok := err.(*knownhosts.KeyError); ok {
+			if len(keyErr.Want) > 0 {
+				return false, true, fmt.Errorf("host key %s doesn't match known hosts", ssh.FingerprintSHA256(p.key))
+			}
+			return false, false, fmt.Errorf("host not found in known hosts")
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+
+	return false, false, nil
+}
+
+// Statistics returns the statistics of the SSHProbe.
+func (p *SSHProbe) Statistics() *Statistics {
+	verified, mismatch, keyErr := p.verify()
+	s := Statistics{
+		Addr:          p.ip,
+		Port:          p.port,
+		Connected:     p.key != nil,
+		HandshakeTime: p.handshakeTime,
+		ServerVersion: p.serverVersion,
+		KeyVerified:   verified,
+		KeyMismatch:   mismatch,
+		KeyError:      keyErr,
+		Error:         p.err,
+	}
+	if p.key != nil {
+		s.KeyType = p.key.Type()
+		s.Fingerprint = ssh.FingerprintSHA256(p.key)
+	}
+	return &s
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/netcat"
+	goping "github.com/migotom/uberping/internal/worker/ping"
+)
+
+// Sweeper worker iterates over schema.Host tasks, pinging each of them and trying to connect to configured
+// sweep ports, only responsive hosts are pushed into config.Results channel.
+func Sweeper(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for device := range jobs {
+		var alive bool
+
+		pinger, err := goping.NewPinger(device.IP)
+		if err != nil {
+			continue
+		}
+		pinger.OnFinish = func(stats *goping.Statistics) {
+			alive = stats.PacketsRecv > 0
+		}
+		pinger.SetPrivileged(config.Probe.Privileged)
+		pinger.Interval = config.Probe.Interval.Duration
+		pinger.Pace = pace(config, device.IP)
+		pinger.Count = config.Probe.Count
+		pinger.Timeout = config.Probe.Timeout.Duration
+		pinger.Run()
+
+		// sweep ports are probed concurrently, open ones are listed in order of configuration
+		sweepPorts := make([]string, len(config.Sweep.Ports))
+		for i, port := range config.Sweep.Ports {
+			sweepPorts[i] = strconv.Itoa(port)
+		}
+		open := make([]bool, len(sweepPorts))
+		probePorts(device.IP, sweepPorts, config.Probe.Timeout.Duration, nil, func(i int, stats *netcat.Statistics) {
+			open[i] = stats.ConnectionsEstablished > 0
+		})
+
+		var ports []string
+		for i, port := range sweepPorts {
+			if open[i] {
+				ports = append(ports, port)
+			}
+		}
+
+		if !alive && len(ports) == 0 {
+			continue
+		}
+
+		line := fmt.Sprintf("Host %s is alive", device.IP)
+		if !alive {
+			line = fmt.Sprintf("Host %s doesn't respond to ping", device.IP)
+		}
+		if len(ports) > 0 {
+			device.Labels = map[string]string{"ports": strings.Join(ports, ",")}
+			line += fmt.Sprintf(", open ports: %s", device.Labels["ports"])
+		}
+
+		result := schema.ProbeResult{Host: device, Output: []string{line}}
+		annotate(config, &result)
+		config.Results <- result
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/twampprobe"
+)
+
+// TWAMP worker iterates over schema.Host tasks, sending TWAMP-Light test packets to reflector running on each of them
+// and push results into config.Results channel.
+func TWAMP(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	probeHosts(config, jobs, func(device schema.Host, result *schema.ProbeResult) error {
+		probe, err := twampprobe.NewTWAMPProbe(device.IP, device.Port)
+		if err != nil {
+			return err
+		}
+
+		probe.OnFinish = func(stats *twampprobe.Statistics) {
+			result.Loss = stats.Loss
+			result.AvgTime = stats.AvgRtt.Seconds()
+
+			if stats.PacketsRecv == 0 {
+				result.Output = append(result.Output, fmt.Sprintf("TWAMP test to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.Error))
+				return
+			}
+
+			result.TWAMP = &schema.TWAMPResult{
+				RoundTrip:     stats.AvgRtt.Seconds(),
+				Forward:       stats.AvgForward.Seconds(),
+				Reverse:       stats.AvgReverse.Seconds(),
+				Jitter:        stats.Jitter.Seconds(),
+				ForwardJitter: stats.ForwardJitter.Seconds(),
+				ReverseJitter: stats.ReverseJitter.Seconds(),
+			}
+
+			var line string
+			line += fmt.Sprintf("\n--- %s twamp statistics ---\n", stats.Addr)
+			line += fmt.Sprintf("%d packets transmitted, %d packets received, %v%% loss",
+				stats.PacketsSent, stats.PacketsRecv, stats.Loss)
+			if stats.Directional {
+				result.TWAMP.ForwardLoss = &stats.ForwardLoss
+				result.TWAMP.ReverseLoss = &stats.ReverseLoss
+				line += fmt.Sprintf(" (forward %v%%, reverse %v%%)", stats.ForwardLoss, stats.ReverseLoss)
+			}
+			line += "\n"
+			line += fmt.Sprintf("round-trip min/avg/max = %v/%v/%v\n", toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt))
+			line += fmt.Sprintf("one-way forward avg/max = %v/%v, reverse avg/max = %v/%v\n",
+				toMs(stats.AvgForward), toMs(stats.MaxForward), toMs(stats.AvgReverse), toMs(stats.MaxReverse))
+			line += fmt.Sprintf("jitter round-trip/forward/reverse = %v/%v/%v\n",
+				toMs(stats.Jitter), toMs(stats.ForwardJitter), toMs(stats.ReverseJitter))
+			result.Output = append(result.Output, line)
+		}
+
+		probe.Interval = config.Probe.Interval.Duration
+		probe.Pace = pace(config, device.IP)
+		probe.Count = config.Probe.Count
+		probe.Timeout = config.Probe.Timeout.Duration
+
+		probe.Run()
+		return nil
+	})
+}
@@ -0,0 +1,117 @@
+package twampprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// unauthenticated mode test packets, RFC 5357 section 4.1.2 and 4.2.1
+	senderHeaderLength    = 14
+	reflectorHeaderLength = 41
+
+	// sender pads test packets to size of reflected ones so both directions carry the same amount of data
+	senderPacketSize = reflectorHeaderLength
+
+	// clock is not synchronized to external source, error estimate with multiplier 1 and scale 0
+	errorEstimate = 0x0001
+
+	// seconds between NTP era (1900) and Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+
+	// built-in reflector marks reflected packets by "UP" in MBZ field following sender error estimate,
+	// so sender knows reflector numbers packets on its own
+	reflectorMark = 0x5550
+)
+
+var errShortPacket = errors.New("too short TWAMP test packet")
+
+// senderPacket is test packet sent by session sender.
+type senderPacket struct {
+	seq       uint32
+	timestamp time.Time
+}
+
+func (p senderPacket) marshal() []byte {
+	b := make([]byte, senderPacketSize)
+	binary.BigEndian.PutUint32(b[0:4], p.seq)
+	binary.BigEndian.PutUint64(b[4:12], toNTPTime(p.timestamp))
+	binary.BigEndian.PutUint16(b[12:14], errorEstimate)
+	return b
+}
+
+func parseSenderPacket(b []byte) (senderPacket, error) {
+	if len(b) < senderHeaderLength {
+		return senderPacket{}, errShortPacket
+	}
+	return senderPacket{
+		seq:       binary.BigEndian.Uint32(b[0:4]),
+		timestamp: fromNTPTime(binary.BigEndian.Uint64(b[4:12])),
+	}, nil
+}
+
+// reflectedPacket is test packet returned by session reflector.
+type reflectedPacket struct {
+	seq       uint32
+	timestamp time.Time
+	received  time.Time
+
+	senderSeq       uint32
+	senderTimestamp time.Time
+	senderTTL       uint8
+
+	// numbered tells if reflector numbers packets on its own instead of copying sequence of sender
+	numbered bool
+}
+
+// marshal encodes reflected packet answering request, sender error estimate and padding are copied from request.
+func (p reflectedPacket) marshal(request []byte) []byte {
+	size := reflectorHeaderLength
+	if len(request) > size {
+		size = len(request)
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:4], p.seq)
+	binary.BigEndian.PutUint64(b[4:12], toNTPTime(p.timestamp))
+	binary.BigEndian.PutUint16(b[12:14], errorEstimate)
+	binary.BigEndian.PutUint64(b[16:24], toNTPTime(p.received))
+	binary.BigEndian.PutUint32(b[24:28], p.senderSeq)
+	binary.BigEndian.PutUint64(b[28:36], toNTPTime(p.senderTimestamp))
+	copy(b[36:38], request[12:14])
+	if p.numbered {
+		binary.BigEndian.PutUint16(b[38:40], reflectorMark)
+	}
+	b[40] = p.senderTTL
+	if len(request) > reflectorHeaderLength {
+		copy(b[reflectorHeaderLength:], request[reflectorHeaderLength:])
+	}
+	return b
+}
+
+func parseReflectedPacket(b []byte) (reflectedPacket, error) {
+	if len(b) < reflectorHeaderLength {
+		return reflectedPacket{}, errShortPacket
+	}
+	return reflectedPacket{
+		seq:             binary.BigEndian.Uint32(b[0:4]),
+		timestamp:       fromNTPTime(binary.BigEndian.Uint64(b[4:12])),
+		received:        fromNTPTime(binary.BigEndian.Uint64(b[16:24])),
+		senderSeq:       binary.BigEndian.Uint32(b[24:28]),
+		senderTimestamp: fromNTPTime(binary.BigEndian.Uint64(b[28:36])),
+		senderTTL:       b[40],
+		numbered:        binary.BigEndian.Uint16(b[38:40]) == reflectorMark,
+	}, nil
+}
+
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
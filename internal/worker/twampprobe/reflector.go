@@ -0,0 +1,138 @@
+package twampprobe
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// sessionTimeout is the time after which idle session of sender is forgotten, REFWAIT of RFC 5357.
+const sessionTimeout = 900 * time.Second
+
+// session keeps sequence of packets reflected to one sender.
+type session struct {
+	seq      uint32
+	lastSeen time.Time
+}
+
+// Reflector is TWAMP-Light session reflector (RFC 5357 appendix I) answering test packets of any sender without control
+// session. Reflector numbers reflected packets of each sender address separately, so sender is able to tell which
+// direction packets were lost in.
+type Reflector struct {
+	conn *net.UDPConn
+	read func([]byte) (int, int, net.Addr, error)
+
+	sessions map[string]*session
+	pruned   time.Time
+
+	// OnSession is called when first packet of new sender is reflected.
+	OnSession func(net.Addr)
+}
+
+// NewReflector returns a new Reflector listening on UDP address.
+func NewReflector(address string) (*Reflector, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reflector{
+		conn:     conn,
+		sessions: make(map[string]*session),
+		pruned:   time.Now(),
+	}
+
+	// TTL of received packets is reported back to sender, it's unknown (zero) if socket doesn't deliver it
+	if pc := ipv4.NewPacketConn(conn); pc.SetControlMessage(ipv4.FlagTTL, true) == nil {
+		r.read = func(b []byte) (int, int, net.Addr, error) {
+			n, cm, addr, err := pc.ReadFrom(b)
+			if cm == nil {
+				return n, 0, addr, err
+			}
+			return n, cm.TTL, addr, err
+		}
+	} else if pc := ipv6.NewPacketConn(conn); pc.SetControlMessage(ipv6.FlagHopLimit, true) == nil {
+		r.read = func(b []byte) (int, int, net.Addr, error) {
+			n, cm, addr, err := pc.ReadFrom(b)
+			if cm == nil {
+				return n, 0, addr, err
+			}
+			return n, cm.HopLimit, addr, err
+		}
+	} else {
+		r.read = func(b []byte) (int, int, net.Addr, error) {
+			n, addr, err := conn.ReadFrom(b)
+			return n, 0, addr, err
+		}
+	}
+	return r, nil
+}
+
+// Addr returns local address of reflector.
+func (r *Reflector) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Serve reflects test packets, this is a blocking function that will exit when reflector is closed.
+func (r *Reflector) Serve() error {
+	buf := make([]byte, 65536)
+	for {
+		n, ttl, addr, err := r.read(buf)
+		received := time.Now()
+		if err != nil {
+			return err
+		}
+
+		request, err := parseSenderPacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		reflected := reflectedPacket{
+			seq:             r.next(addr, received),
+			received:        received,
+			senderSeq:       request.seq,
+			senderTimestamp: request.timestamp,
+			senderTTL:       uint8(ttl),
+			numbered:        true,
+		}
+		reflected.timestamp = time.Now()
+		r.conn.WriteTo(reflected.marshal(buf[:n]), addr)
+	}
+}
+
+// Close stops reflector.
+func (r *Reflector) Close() error {
+	return r.conn.Close()
+}
+
+// next returns sequence number of packet reflected to sender, sessions idle for longer than sessionTimeout are forgotten.
+func (r *Reflector) next(addr net.Addr, now time.Time) uint32 {
+	if now.Sub(r.pruned) > sessionTimeout {
+		for key, s := range r.sessions {
+			if now.Sub(s.lastSeen) > sessionTimeout {
+				delete(r.sessions, key)
+			}
+		}
+		r.pruned = now
+	}
+
+	s, ok := r.sessions[addr.String()]
+	if !ok {
+		s = &session{}
+		r.sessions[addr.String()] = s
+		if handler := r.OnSession; handler != nil {
+			handler(addr)
+		}
+	} else {
+		s.seq++
+	}
+	s.lastSeen = now
+	return s.seq
+}
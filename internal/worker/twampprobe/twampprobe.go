@@ -0,0 +1,316 @@
+package twampprobe
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/migotom/uberping/internal/worker/rttstats"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// TWAMPProbe sends TWAMP-Light test packets to session reflector and measures two-way and one-way delays, jitter and loss.
+type TWAMPProbe struct {
+	ip   string
+	port string
+
+	// Count tells probe to stop after sending Count packets.
+	Count int
+
+	// Interval is the wait time between each packet.
+	Interval time.Duration
+
+	// Timeout specifies how long to wait for reflected packets after the last one was sent.
+	Timeout time.Duration
+
+	// Pace is called before sending each test packet and may delay it.
+	Pace func()
+
+	packetsSent int
+	samples     []sample
+	err         error
+
+	// OnFinish is called when TWAMPProbe exits
+	OnFinish func(*Statistics)
+}
+
+// sample is one reflected packet with all four timestamps of its trip.
+type sample struct {
+	seq, reflectorSeq uint32
+
+	// numbered tells if reflector numbers packets on its own
+	numbered bool
+
+	// sent by sender, received by reflector, sent by reflector and received by sender
+	t1, t2, t3, t4 time.Time
+}
+
+func (s sample) rtt() time.Duration {
+	return s.t4.Sub(s.t1) - s.t3.Sub(s.t2)
+}
+
+func (s sample) forward() time.Duration {
+	return s.t2.Sub(s.t1)
+}
+
+func (s sample) reverse() time.Duration {
+	return s.t4.Sub(s.t3)
+}
+
+// Statistics represent the stats of a TWAMPProbe
+type Statistics struct {
+	// Addr is the string address of the host being probed.
+	Addr string
+
+	// Port is reflector port.
+	Port string
+
+	// PacketsSent is the number of test packets sent.
+	PacketsSent int
+
+	// PacketsRecv is the number of reflected packets received.
+	PacketsRecv int
+
+	// Loss is the percentage of packets lost.
+	Loss float64
+
+	// Directional tells if loss of each direction is known, it requires reflector numbering reflected packets on its own.
+	Directional bool
+
+	// ForwardLoss is the percentage of packets lost on the way to reflector.
+	ForwardLoss float64
+
+	// ReverseLoss is the percentage of reflected packets lost on the way back.
+	ReverseLoss float64
+
+	// MinRtt is the minimum round-trip time excluding reflector processing time.
+	MinRtt time.Duration
+
+	// MaxRtt is the maximum round-trip time excluding reflector processing time.
+	MaxRtt time.Duration
+
+	// AvgRtt is the average round-trip time excluding reflector processing time.
+	AvgRtt time.Duration
+
+	// AvgForward is the average one-way delay to reflector, it's biased by clock offset of reflector.
+	AvgForward time.Duration
+
+	// MaxForward is the maximum one-way delay to reflector.
+	MaxForward time.Duration
+
+	// AvgReverse is the average one-way delay from reflector, it's biased by clock offset of reflector.
+	AvgReverse time.Duration
+
+	// MaxReverse is the maximum one-way delay from reflector.
+	MaxReverse time.Duration
+
+	// Jitter is the mean difference of round-trip times of consecutive packets.
+	Jitter time.Duration
+
+	// ForwardJitter is the mean difference of one-way delays to reflector of consecutive packets.
+	ForwardJitter time.Duration
+
+	// ReverseJitter is the mean difference of one-way delays from reflector of consecutive packets.
+	ReverseJitter time.Duration
+
+	// Error specifies the last error.
+	Error error
+}
+
+// NewTWAMPProbe returns a new TWAMPProbe struct pointer.
+func NewTWAMPProbe(host, port string) (*TWAMPProbe, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("wrong port number: %v", port)
+	}
+
+	return &TWAMPProbe{
+		ip:       ip.String(),
+		port:     port,
+		Count:    1,
+		Interval: time.Second,
+		Timeout:  time.Second,
+	}, nil
+}
+
+// Run sends test packets, this is a blocking function that will exit when it's done.
+func (p *TWAMPProbe) Run() {
+	p.run()
+
+	handler := p.OnFinish
+	if handler != nil {
+		s := p.Statistics()
+		handler(s)
+	}
+}
+
+func (p *TWAMPProbe) run() {
+	conn, err := net.Dial("udp", net.JoinHostPort(p.ip, p.port))
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer conn.Close()
+
+	// reflector reports TTL of received packets, starting from maximum tells how many hops test packets passed
+	if net.ParseIP(p.ip).To4() != nil {
+		ipv4.NewConn(conn).SetTTL(255)
+	} else {
+		ipv6.NewConn(conn).SetHopLimit(255)
+	}
+
+	// reflected packets are collected concurrently, till the last one or timeout after sending the last test packet,
+	// paced sending may take longer so only the latter applies then
+	if p.Pace == nil {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(p.Count)*p.Interval + p.Timeout))
+	}
+	received := make(chan error)
+	go func() {
+		received <- p.receive(conn)
+	}()
+
+	var sendErr error
+	for p.packetsSent < p.Count {
+		if p.packetsSent > 0 {
+			time.Sleep(p.Interval)
+		}
+
+		if p.Pace != nil {
+			p.Pace()
+		}
+		packet := senderPacket{seq: uint32(p.packetsSent), timestamp: time.Now()}
+		p.packetsSent++
+		if _, err := conn.Write(packet.marshal()); err != nil {
+			sendErr = err
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(p.Timeout))
+
+	p.err = <-received
+	if sendErr != nil {
+		p.err = sendErr
+	}
+}
+
+// receive reads reflected packets until all of them are received or read deadline expires.
+func (p *TWAMPProbe) receive(conn net.Conn) error {
+	var lastErr error
+	seen := make(map[uint32]bool)
+	buf := make([]byte, 65536)
+	for len(p.samples) < p.Count {
+		n, err := conn.Read(buf)
+		t4 := time.Now()
+		if err != nil {
+			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+				if lastErr == nil && len(p.samples) == 0 {
+					lastErr = err
+				}
+				return lastErr
+			}
+			// e.g. ICMP port unreachable of closed reflector port
+			lastErr = err
+			continue
+		}
+
+		reflected, err := parseReflectedPacket(buf[:n])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if int(reflected.senderSeq) >= p.Count || seen[reflected.senderSeq] {
+			// duplicated or garbage
+			continue
+		}
+		seen[reflected.senderSeq] = true
+
+		p.samples = append(p.samples, sample{
+			seq:          reflected.senderSeq,
+			reflectorSeq: reflected.seq,
+			numbered:     reflected.numbered,
+			t1:           reflected.senderTimestamp,
+			t2:           reflected.received,
+			t3:           reflected.timestamp,
+			t4:           t4,
+		})
+	}
+	return nil
+}
+
+// jitter returns mean absolute difference of consecutive values.
+func jitter(values []time.Duration) time.Duration {
+	if len(values) < 2 {
+		return 0
+	}
+	var total time.Duration
+	for i := 1; i < len(values); i++ {
+		d := values[i] - values[i-1]
+		if d < 0 {
+			d = -d
+		}
+		total += d
+	}
+	return total / time.Duration(len(values)-1)
+}
+
+// Statistics returns the statistics of the TWAMPProbe.
+func (p *TWAMPProbe) Statistics() *Statistics {
+	s := Statistics{
+		Addr:        p.ip,
+		Port:        p.port,
+		PacketsSent: p.packetsSent,
+		PacketsRecv: len(p.samples),
+		Error:       p.err,
+	}
+	if p.packetsSent > 0 {
+		s.Loss = float64(p.packetsSent-len(p.samples)) / float64(p.packetsSent) * 100
+	} else {
+		s.Loss = 100
+	}
+	if len(p.samples) == 0 {
+		return &s
+	}
+
+	samples := make([]sample, len(p.samples))
+	copy(samples, p.samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].seq < samples[j].seq })
+
+	// number of reflected packets is known from sequence of reflector, packets reflected before the first or after
+	// the last received are counted as forward loss, reflector copying sequence of sender doesn't tell where packets
+	// were lost as well as reflector numbering packets on its own when all of them were lost on the way back,
+	// numbering of other reflectors is known only if sequences differ, i.e. after packet lost on the way to reflector
+	first, last := samples[0], samples[len(samples)-1]
+	s.Directional = len(samples) == p.packetsSent
+	for _, sample := range samples {
+		if sample.numbered || sample.seq != sample.reflectorSeq {
+			s.Directional = true
+			break
+		}
+	}
+	if reflected := int(last.reflectorSeq-first.reflectorSeq) + 1; s.Directional && reflected <= p.packetsSent && reflected >= len(samples) {
+		s.ForwardLoss = float64(p.packetsSent-reflected) / float64(p.packetsSent) * 100
+		s.ReverseLoss = float64(reflected-len(samples)) / float64(reflected) * 100
+	} else {
+		s.Directional = false
+	}
+
+	var rtts, forwards, reverses []time.Duration
+	for _, sample := range samples {
+		rtts = append(rtts, sample.rtt())
+		forwards = append(forwards, sample.forward())
+		reverses = append(reverses, sample.reverse())
+	}
+	s.MinRtt, s.AvgRtt, s.MaxRtt = rttstats.MinAvgMax(rtts)
+	_, s.AvgForward, s.MaxForward = rttstats.MinAvgMax(forwards)
+	_, s.AvgReverse, s.MaxReverse = rttstats.MinAvgMax(reverses)
+	s.Jitter = jitter(rtts)
+	s.ForwardJitter = jitter(forwards)
+	s.ReverseJitter = jitter(reverses)
+	return &s
+}
@@ -0,0 +1,139 @@
+package twampprobe
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPackets(t *testing.T) {
+	t1 := time.Unix(1600000000, 123456789)
+	request := senderPacket{seq: 7, timestamp: t1}.marshal()
+	if len(request) != reflectorHeaderLength {
+		t.Errorf("sender packet should be padded to %d bytes, got: %d", reflectorHeaderLength, len(request))
+	}
+
+	sent, err := parseSenderPacket(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.seq != 7 || sent.timestamp.Sub(t1) > time.Nanosecond || t1.Sub(sent.timestamp) > time.Nanosecond {
+		t.Errorf("invalid sender packet, got: %v %v", sent.seq, sent.timestamp)
+	}
+
+	t2 := t1.Add(10 * time.Millisecond)
+	response := reflectedPacket{
+		seq:             3,
+		timestamp:       t2.Add(time.Millisecond),
+		received:        t2,
+		senderSeq:       sent.seq,
+		senderTimestamp: sent.timestamp,
+		senderTTL:       254,
+		numbered:        true,
+	}.marshal(request)
+
+	reflected, err := parseReflectedPacket(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflected.seq != 3 || reflected.senderSeq != 7 || reflected.senderTTL != 254 || !reflected.numbered {
+		t.Errorf("invalid reflected packet, got: %+v", reflected)
+	}
+	if d := reflected.timestamp.Sub(reflected.received); d < time.Millisecond-time.Microsecond || d > time.Millisecond+time.Microsecond {
+		t.Errorf("invalid reflector timestamps, got: %v", d)
+	}
+
+	if _, err := parseReflectedPacket(request[:senderHeaderLength]); err == nil {
+		t.Error("too short reflected packet accepted")
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	at := func(ms int) time.Time {
+		return time.Unix(1600000000, 0).Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	// 10 packets sent, 0 and 9 lost on the way to reflector, 5 lost on the way back
+	p := &TWAMPProbe{packetsSent: 10}
+	// forward delay alternates between 10ms and 12ms, reflector processing takes 1ms
+	for seq := uint32(1); seq < 9; seq++ {
+		if seq == 5 {
+			continue
+		}
+		start, forward := int(seq)*100, 10+len(p.samples)%2*2
+		p.samples = append(p.samples, sample{seq: seq, reflectorSeq: seq - 1, t1: at(start), t2: at(start + forward), t3: at(start + forward + 1), t4: at(start + 31)})
+	}
+
+	s := p.Statistics()
+	if s.PacketsRecv != 7 || s.Loss != 30 {
+		t.Errorf("invalid loss, got: %v received, %v%%", s.PacketsRecv, s.Loss)
+	}
+	if !s.Directional || s.ForwardLoss != 20 || s.ReverseLoss != 12.5 {
+		t.Errorf("invalid directional loss, got: %v forward %v%%, reverse %v%%", s.Directional, s.ForwardLoss, s.ReverseLoss)
+	}
+	if s.AvgRtt != 30*time.Millisecond || s.MinRtt != s.AvgRtt || s.MaxRtt != s.AvgRtt || s.Jitter != 0 {
+		t.Errorf("invalid round-trip times, got: %v/%v/%v jitter %v", s.MinRtt, s.AvgRtt, s.MaxRtt, s.Jitter)
+	}
+	if s.MaxForward != 12*time.Millisecond || s.ForwardJitter != 2*time.Millisecond || s.ReverseJitter != 2*time.Millisecond {
+		t.Errorf("invalid one-way delays, got: forward max %v jitter %v, reverse jitter %v", s.MaxForward, s.ForwardJitter, s.ReverseJitter)
+	}
+
+	// reflector copying sequence of sender
+	for i := range p.samples {
+		p.samples[i].reflectorSeq = p.samples[i].seq
+	}
+	if s := p.Statistics(); s.Directional {
+		t.Error("loss of reflector copying sequence of sender reported as directional")
+	}
+}
+
+func TestReverseLoss(t *testing.T) {
+	at := func(ms int) time.Time {
+		return time.Unix(1600000000, 0).Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	// 10 packets sent, 3 and 6 lost on the way back, reflector numbering matches sequence of sender
+	p := &TWAMPProbe{packetsSent: 10}
+	for seq := uint32(0); seq < 10; seq++ {
+		if seq == 3 || seq == 6 {
+			continue
+		}
+		start := int(seq) * 100
+		p.samples = append(p.samples, sample{seq: seq, reflectorSeq: seq, numbered: true, t1: at(start), t2: at(start + 10), t3: at(start + 11), t4: at(start + 21)})
+	}
+
+	s := p.Statistics()
+	if !s.Directional || s.ForwardLoss != 0 || s.ReverseLoss != 20 {
+		t.Errorf("invalid directional loss, got: %v forward %v%%, reverse %v%%", s.Directional, s.ForwardLoss, s.ReverseLoss)
+	}
+
+	// unknown reflector may copy sequence of sender
+	for i := range p.samples {
+		p.samples[i].numbered = false
+	}
+	if s := p.Statistics(); s.Directional {
+		t.Error("loss of unmarked reflector with matching sequence reported as directional")
+	}
+}
+
+func TestReflectorNumbering(t *testing.T) {
+	r, err := NewReflector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	_, port, _ := net.SplitHostPort(r.Addr().String())
+	p, err := NewTWAMPProbe("127.0.0.1", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Count = 2
+	p.Interval = time.Millisecond
+	p.Run()
+
+	if len(p.samples) != 2 || !p.samples[0].numbered {
+		t.Errorf("expected 2 packets numbered by built-in reflector, got: %+v", p.samples)
+	}
+}
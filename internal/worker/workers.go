@@ -3,6 +3,8 @@ package worker
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +20,101 @@ func toMs(duration time.Duration) string {
 	return fmt.Sprintf("%.3fms", float64(duration.Nanoseconds())/1e6)
 }
 
+// resolve refreshes IP address of host defined by name using shared resolver cache,
+// if name can't be resolved RESOLVE_ERROR result is reported and false returned.
+func resolve(config schema.GeneralConfig, device *schema.Host) bool {
+	if device.Hostname == "" || config.Resolver.Client == nil {
+		return true
+	}
+
+	ip, err := config.Resolver.Client.Resolve(device.Hostname)
+	if err != nil {
+		config.Results <- schema.ProbeResult{
+			Host:   *device,
+			Status: schema.StatusResolveError,
+			Output: []string{fmt.Sprintf("Can't resolve host %s, %v!\n", device.Hostname, err)},
+			Loss:   100,
+		}
+		return false
+	}
+	device.IP = ip
+	return true
+}
+
+// unprobed reports CRITICAL result of host which can't be probed, e.g. because of invalid port, so other hosts
+// are still probed.
+func unprobed(config schema.GeneralConfig, device schema.Host, err error) {
+	config.Results <- schema.ProbeResult{
+		Host:     device,
+		Status:   schema.StatusCritical,
+		Output:   []string{fmt.Sprintf("Can't probe host %s, %v!\n", device.IP, err)},
+		Loss:     100,
+		Unprobed: true,
+	}
+}
+
+// probeHosts iterates over schema.Host tasks, probing each resolved host by probe function filling its result,
+// results are annotated and pushed into config.Results channel, host which probe returns error is reported by unprobed.
+func probeHosts(config schema.GeneralConfig, jobs <-chan schema.Host, probe func(device schema.Host, result *schema.ProbeResult) error) {
+	for device := range jobs {
+		var result schema.ProbeResult
+
+		if !resolve(config, &device) {
+			continue
+		}
+
+		if err := probe(device, &result); err != nil {
+			unprobed(config, device, err)
+			continue
+		}
+
+		result.Host = device
+		annotate(config, &result)
+		config.Results <- result
+	}
+}
+
+// pace returns function delaying packets sent to ip according to shared packets per second limits,
+// or nil if packets aren't limited.
+func pace(config schema.GeneralConfig, ip string) func() {
+	if config.Pacing.Client == nil {
+		return nil
+	}
+	return func() {
+		config.Pacing.Client.Wait(ip)
+	}
+}
+
+// annotate attaches annotations of probed IP address to result and its output.
+func annotate(config schema.GeneralConfig, result *schema.ProbeResult) {
+	if config.Enrichment.Client == nil || result.Host.IP == "" {
+		return
+	}
+
+	a := config.Enrichment.Client.Annotate(result.Host.IP)
+	result.Annotations = a
+
+	var fields []string
+	if a.PTR != "" {
+		fields = append(fields, "ptr="+a.PTR)
+	}
+	if a.Country != "" {
+		fields = append(fields, "country="+a.Country)
+	}
+	if a.City != "" {
+		fields = append(fields, "city="+a.City)
+	}
+	if a.ASN != 0 {
+		fields = append(fields, fmt.Sprintf("asn=AS%d", a.ASN))
+	}
+	if a.Org != "" {
+		fields = append(fields, "org="+a.Org)
+	}
+	if len(fields) > 0 {
+		result.Output = append(result.Output, fmt.Sprintf("%s: %s\n", result.Host.IP, strings.Join(fields, ", ")))
+	}
+}
+
 // Cleaner makes sure that all handles/sockets are closed before exiting app.
 func Cleaner(config schema.GeneralConfig, cleaners []schema.HostsCleaner) {
 	for _, cleaner := range cleaners {
@@ -64,37 +161,165 @@ func Saver(config schema.GeneralConfig, savers []ResultsSaver, wg *sync.WaitGrou
 	wgSavers.Wait()
 }
 
-// Netcat worer iterates over hosts tasks and try to establish to each of them connection to specified service.
+// script returns send/expect steps of script assigned to host by label script or group, error is returned
+// if label script names script which isn't configured.
+func script(config schema.GeneralConfig, device schema.Host) ([]netcat.Step, error) {
+	name, ok := device.Labels["script"]
+	if !ok {
+		group := device.Labels["group"]
+		var names []string
+		for n, s := range config.Scripts {
+			for _, g := range s.Groups {
+				if group != "" && g == group {
+					names = append(names, n)
+				}
+			}
+		}
+		if len(names) == 0 {
+			return nil, nil
+		}
+		sort.Strings(names)
+		name = names[0]
+	}
+
+	configured, ok := config.Scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown script %s", name)
+	}
+
+	var steps []netcat.Step
+	for _, step := range configured.Steps {
+		steps = append(steps, netcat.Step{Send: step.Send, Expect: step.Pattern, Timeout: step.Timeout.Duration})
+	}
+	return steps, nil
+}
+
+// netcatConcurrency limits number of ports of one host probed at once.
+const netcatConcurrency = 64
+
+// probePorts connects to ports of ip concurrently, at most netcatConcurrency at once, handler is called with index
+// and statistics of each port.
+func probePorts(ip string, ports []string, timeout time.Duration, steps []netcat.Step, handler func(int, *netcat.Statistics)) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	semaphore := make(chan struct{}, netcatConcurrency)
+	for i, port := range ports {
+		nc, err := netcat.NewNetcat(ip, port)
+		if err != nil {
+			return err
+		}
+
+		i := i
+		nc.OnFinish = func(stats *netcat.Statistics) {
+			handler(i, stats)
+		}
+		nc.Timeout = timeout
+		nc.Script = steps
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			nc.Run()
+			<-semaphore
+		}()
+	}
+	return nil
+}
+
+// Netcat worer iterates over hosts tasks and try to establish to each of them connection to specified services,
+// ports of one host are probed concurrently.
 func Netcat(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for device := range jobs {
 		var result schema.ProbeResult
 
-		nc, err := netcat.NewNetcat(device.IP, device.Port)
+		if !resolve(config, &device) {
+			continue
+		}
+
+		ports, err := schema.ParsePorts(device.Port)
 		if err != nil {
-			log.Fatalln(err.Error())
-			return
+			unprobed(config, device, err)
+			continue
 		}
 
-		nc.OnFinish = func(stats *netcat.Statistics) {
-			var line string
-			if stats.ConnectionLoss < 100.0 {
-				line = fmt.Sprintf("Connection to %s:%s succeded, time=%v!\n", stats.Addr, stats.Port, toMs(stats.Rtt))
+		steps, err := script(config, device)
+		if err != nil {
+			unprobed(config, device, err)
+			continue
+		}
+		result.Services = make([]schema.ServiceResult, len(ports))
+		lines := make([]string, len(ports))
+
+		err = probePorts(device.IP, ports, config.Probe.Timeout.Duration, steps, func(i int, stats *netcat.Statistics) {
+			service := schema.ServiceResult{Port: stats.Port, Banner: stats.Banner, FailedStep: stats.FailedStep}
+			if stats.ConnectionLoss < 100.0 && stats.ScriptError != nil {
+				service.Time = stats.Rtt.Seconds()
+				service.Error = stats.ScriptError.Error()
+				lines[i] = fmt.Sprintf("Connection to %s:%s established, script step %d failed, %v!\n", stats.Addr, stats.Port, stats.FailedStep, stats.ScriptError)
+			} else if stats.ConnectionLoss < 100.0 {
+				service.Reachable = true
+				service.Time = stats.Rtt.Seconds()
+				lines[i] = fmt.Sprintf("Connection to %s:%s succeded, time=%v!\n", stats.Addr, stats.Port, toMs(stats.Rtt))
+				if stats.Banner != "" {
+					lines[i] = fmt.Sprintf("Connection to %s:%s succeded, time=%v, banner: %s!\n", stats.Addr, stats.Port, toMs(stats.Rtt), stats.Banner)
+				}
 			} else {
-				line = fmt.Sprintf("Connection to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.ConnectionError)
+				service.Error = stats.ConnectionError.Error()
+				lines[i] = fmt.Sprintf("Connection to %s:%s failed, %v!\n", stats.Addr, stats.Port, stats.ConnectionError)
 			}
-			result.Output = append(result.Output, line)
-			result.Loss = stats.ConnectionLoss
-			result.AvgTime = stats.Rtt.Seconds()
-			result.Host = device
-			config.Results <- result
+			result.Services[i] = service
+		})
+		if err != nil {
+			unprobed(config, device, err)
+			continue
 		}
 
-		nc.Timeout = config.Probe.Timeout.Duration
+		// host rollup: loss is percentage of unreachable services, time is average of reachable ones
+		var reachable int
+		var total float64
+		for _, service := range result.Services {
+			if service.Reachable {
+				reachable++
+				total += service.Time
+			}
+		}
+		result.Output = lines
+		if len(ports) > 1 {
+			result.Output = append(result.Output, fmt.Sprintf("%s: %d/%d services reachable\n", device.IP, reachable, len(ports)))
+		}
+		result.Loss = float64(len(ports)-reachable) / float64(len(ports)) * 100
+		if reachable > 0 {
+			result.AvgTime = total / float64(reachable)
+		}
+		result.Host = device
+		annotate(config, &result)
+		config.Results <- result
+	}
+}
 
-		nc.Run()
+// icmpErrors converts counted ICMP error messages into results, the most frequent first.
+func icmpErrors(errors map[goping.ICMPError]int) []schema.ICMPErrorResult {
+	var results []schema.ICMPErrorResult
+	for e, count := range errors {
+		results = append(results, schema.ICMPErrorResult{
+			Type:        e.Type,
+			Code:        e.Code,
+			Description: e.Description,
+			From:        e.From,
+			Count:       count,
+		})
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].From+results[i].Description < results[j].From+results[j].Description
+	})
+	return results
 }
 
 // Pinger worker iterates over schema.Host tasks, running Ping command for each of them and push results into config.Results channel.
@@ -105,6 +330,10 @@ func Pinger(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sy
 	for device := range jobs {
 		var result schema.ProbeResult
 
+		if !resolve(config, &device) {
+			continue
+		}
+
 		pinger, err := goping.NewPinger(device.IP)
 		if err != nil {
 			log.Fatalln(err.Error())
@@ -115,6 +344,15 @@ func Pinger(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sy
 
 			line := fmt.Sprintf("%d bytes from %s: icmp_seq=%d time=%v",
 				pkt.Nbytes, pkt.IPAddr, pkt.Seq, toMs(pkt.Rtt))
+			if pkt.Corrupted {
+				line = fmt.Sprintf("%d bytes from %s: icmp_seq=%d corrupted payload", pkt.Nbytes, pkt.IPAddr, pkt.Seq)
+			}
+			if pkt.Error != nil {
+				line = fmt.Sprintf("From %s: icmp_seq=%d %s", pkt.Error.From, pkt.Seq, pkt.Error.Description)
+			}
+			if pkt.OneWay {
+				line += fmt.Sprintf(" forward=%v reverse=%v offset=%v", toMs(pkt.Forward), toMs(pkt.Reverse), toMs(pkt.Offset))
+			}
 
 			if config.Verbose && !config.Grouped {
 				fmt.Println(line)
@@ -124,23 +362,63 @@ func Pinger(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sy
 		}
 
 		pinger.OnFinish = func(stats *goping.Statistics) {
+			if stats.Error != nil {
+				unprobed(config, device, stats.Error)
+				return
+			}
+
 			var line string
 
 			line += fmt.Sprintf("\n--- %s ping statistics ---\n", stats.Addr)
 			line += fmt.Sprintf("%d packets transmitted, %d packets received, %v packet loss\n",
 				stats.PacketsSent, stats.PacketsRecv, stats.PacketLoss)
-			line += fmt.Sprintf("round-trip min/avg/max/stddev = %v/%v/%v/%v\n",
+			if stats.PacketsCorrupted > 0 {
+				result.Status = schema.StatusWarning
+				line += fmt.Sprintf("%d replies corrupted, %v%% packet corruption!\n", stats.PacketsCorrupted, stats.PacketCorruption)
+			}
+			for _, icmpErr := range icmpErrors(stats.Errors) {
+				result.ICMPErrors = append(result.ICMPErrors, icmpErr)
+				line += fmt.Sprintf("%d ICMP errors %s from %s\n", icmpErr.Count, icmpErr.Description, icmpErr.From)
+			}
+			line += fmt.Sprintf("round-trip min/avg/max/stddev = %v/%v/%v/%v",
 				toMs(stats.MinRtt), toMs(stats.AvgRtt), toMs(stats.MaxRtt), toMs(stats.StdDevRtt))
+			if stats.TimestampSource == goping.TimestampSourceKernel {
+				line += " (kernel timestamps)"
+			}
+			line += "\n"
+			if stats.OneWay {
+				line += fmt.Sprintf("one-way forward avg/max = %v/%v, reverse avg/max = %v/%v, clock offset = %v\n",
+					toMs(stats.AvgForward), toMs(stats.MaxForward), toMs(stats.AvgReverse), toMs(stats.MaxReverse), toMs(stats.AvgOffset))
+				result.Timestamp = &schema.TimestampResult{
+					Forward:    stats.AvgForward.Seconds(),
+					MaxForward: stats.MaxForward.Seconds(),
+					Reverse:    stats.AvgReverse.Seconds(),
+					MaxReverse: stats.MaxReverse.Seconds(),
+					Offset:     stats.AvgOffset.Seconds(),
+				}
+			}
 
 			result.Output = append(result.Output, line)
 			result.Loss = stats.PacketLoss
+			result.Corruption = stats.PacketCorruption
+			result.TimestampSource = stats.TimestampSource
 			result.AvgTime = stats.AvgRtt.Seconds()
 			result.Host = device
+			annotate(config, &result)
 			config.Results <- result
 		}
 
 		pinger.SetPrivileged(config.Probe.Privileged)
+		pinger.Timestamp = config.Probe.Mode == "timestamp"
+		pinger.KernelTimestamps = config.Probe.Ping.KernelTimestamps
+		if config.Probe.Ping.Size > 0 {
+			pinger.Size = config.Probe.Ping.Size
+		}
+		if config.Probe.Ping.Payload != nil {
+			pinger.Pattern = config.Probe.Ping.Payload
+		}
 		pinger.Interval = config.Probe.Interval.Duration
+		pinger.Pace = pace(config, device.IP)
 		pinger.Count = config.Probe.Count
 		pinger.Timeout = config.Probe.Timeout.Duration
 
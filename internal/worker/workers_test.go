@@ -1,11 +1,31 @@
 package worker
 
 import (
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
+	"text/template"
 	"time"
 
+	"github.com/gosnmp/gosnmp"
 	"github.com/migotom/uberping/internal/schema"
+	"github.com/migotom/uberping/internal/worker/snmpprobe"
+	"github.com/migotom/uberping/internal/worker/twampprobe"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestToMs(t *testing.T) {
@@ -15,6 +35,92 @@ func TestToMs(t *testing.T) {
 	}
 }
 
+func TestRateLimited(t *testing.T) {
+	cases := []struct {
+		burstLoss, trainLoss float64
+		limited              bool
+	}{
+		{0, 0, false},
+		{60, 0, true},
+		{30, 0, true},
+		{40, 25, false},
+		{100, 50, true},
+		{100, 100, false},
+		{0, 50, false},
+	}
+	for _, c := range cases {
+		if limited := rateLimited(c.burstLoss, c.trainLoss, 30); limited != c.limited {
+			t.Errorf("burst loss %v%% and train loss %v%% expected limited %v, got: %v", c.burstLoss, c.trainLoss, c.limited, limited)
+		}
+	}
+}
+
+func TestConfirmer(t *testing.T) {
+	jobs := make(chan schema.Host, 1)
+	config := schema.GeneralConfig{
+		Results: make(chan schema.ProbeResult, 4),
+		Probe:   schema.ProbeConfig{Count: 4},
+	}
+	confirmer := NewConfirmer(schema.ConfirmConfig{Attempts: 2, Delay: schema.Duration{Duration: time.Millisecond}, Count: 10}, jobs)
+
+	// 192.0.2.1 recovers on first confirmation probe sending 10 packets, 192.0.2.2 is down,
+	// 192.0.2.3 can't be probed and host.invalid can't be resolved
+	var mu sync.Mutex
+	probes := make(map[string][]int)
+	probe := func(id int, config schema.GeneralConfig, jobs <-chan schema.Host, wg *sync.WaitGroup) {
+		defer wg.Done()
+		for device := range jobs {
+			mu.Lock()
+			probes[device.IP] = append(probes[device.IP], config.Probe.Count)
+			mu.Unlock()
+
+			result := schema.ProbeResult{Host: device, Loss: 100}
+			switch {
+			case device.IP == "192.0.2.1" && config.Probe.Count == 10:
+				result.Loss = 0
+			case device.IP == "192.0.2.3":
+				result.Status = schema.StatusCritical
+				result.Unprobed = true
+			case device.IP == "host.invalid":
+				result.Status = schema.StatusResolveError
+			}
+			config.Results <- result
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go confirmer.Worker(probe)(0, config, jobs, &wg)
+
+	confirmer.Push(schema.Host{IP: "192.0.2.1"})
+	confirmer.Push(schema.Host{IP: "192.0.2.2"})
+	confirmer.Push(schema.Host{IP: "192.0.2.3"})
+	confirmer.Push(schema.Host{IP: "host.invalid"})
+	confirmer.Wait()
+	close(jobs)
+	wg.Wait()
+	close(config.Results)
+
+	results := make(map[string]schema.ProbeResult)
+	for result := range config.Results {
+		results[result.Host.IP] = result
+	}
+	if r := results["192.0.2.1"]; r.Loss != 0 || r.Confirmations != 1 {
+		t.Errorf("expected 192.0.2.1 recovered by 1 confirmation, got: loss %v, confirmations %d", r.Loss, r.Confirmations)
+	}
+	if r := results["192.0.2.2"]; r.Loss != 100 || r.Confirmations != 2 {
+		t.Errorf("expected 192.0.2.2 down after 2 confirmations, got: loss %v, confirmations %d", r.Loss, r.Confirmations)
+	}
+	if expected := []int{4, 10, 10}; !reflect.DeepEqual(probes["192.0.2.2"], expected) {
+		t.Errorf("expected 192.0.2.2 probed with counts %v, got: %v", expected, probes["192.0.2.2"])
+	}
+	for _, ip := range []string{"192.0.2.3", "host.invalid"} {
+		if len(probes[ip]) != 1 || results[ip].Confirmations != 0 {
+			t.Errorf("expected %s probed once without confirmations, got: %v", ip, probes[ip])
+		}
+	}
+}
+
 func TestSaver(t *testing.T) {
 	var config schema.GeneralConfig
 	config.Results = make(chan schema.ProbeResult, 1)
@@ -67,3 +173,661 @@ func TestPinger(t *testing.T) {
 		t.Errorf("missing pinger output, got: %v", result.Output)
 	}
 }
+
+func TestNetcat(t *testing.T) {
+	var config schema.GeneralConfig
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+
+	var ports []string
+	for i := 0; i < 3; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		ports = append(ports, port)
+	}
+	// last port closed
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, port, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+	ports = append(ports, port)
+
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go Netcat(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: strings.Join(ports, ",")}
+	result := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if len(result.Services) != 4 || !result.Services[0].Reachable || result.Services[3].Reachable {
+		t.Errorf("invalid services results, got: %v", result.Services)
+	}
+	if result.Loss != 25 {
+		t.Errorf("expected 25%% loss, got: %v", result.Loss)
+	}
+	if rollup := result.Output[len(result.Output)-1]; rollup != "127.0.0.1: 3/4 services reachable\n" {
+		t.Errorf("invalid rollup, got: %v", rollup)
+	}
+}
+
+func TestNetcatScript(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+			buf := make([]byte, 64)
+			conn.Read(buf)
+			conn.Write([]byte("500 unknown command\r\n"))
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	var config schema.GeneralConfig
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Scripts = map[string]schema.ScriptConfig{
+		"smtp": {
+			Groups: []string{"mail"},
+			Steps: []schema.ScriptStep{
+				{Pattern: regexp.MustCompile("(?m)^220")},
+				{Send: "EHLO uping\r\n", Pattern: regexp.MustCompile("(?m)^250")},
+			},
+		},
+	}
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go Netcat(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port, Labels: map[string]string{"group": "mail"}}
+	result := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	service := result.Services[0]
+	if service.Reachable || service.FailedStep != 2 || service.Banner != "220 mail.example.com ESMTP" {
+		t.Errorf("expected failure of script step 2, got: %v", service)
+	}
+}
+
+func TestNetcatInvalidPort(t *testing.T) {
+	config := schema.GeneralConfig{Results: make(chan schema.ProbeResult, 1)}
+	jobs := make(chan schema.Host, 1)
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "0"}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Netcat(1, config, jobs, &wg)
+
+	if result := <-config.Results; result.Status != schema.StatusCritical || result.Loss != 100 {
+		t.Errorf("host with invalid port expected CRITICAL, got: %v", result.Status)
+	}
+}
+
+func TestNetcatUnknownScript(t *testing.T) {
+	config := schema.GeneralConfig{Results: make(chan schema.ProbeResult, 1)}
+	config.Scripts = map[string]schema.ScriptConfig{"smtp": {}}
+	jobs := make(chan schema.Host, 1)
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "25", Labels: map[string]string{"script": "smpt"}}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Netcat(1, config, jobs, &wg)
+
+	result := <-config.Results
+	if result.Status != schema.StatusCritical || !strings.Contains(result.Output[0], "unknown script smpt") {
+		t.Errorf("host with unknown script expected CRITICAL, got: %v %v", result.Status, result.Output)
+	}
+}
+
+func TestSSH(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, fmt.Errorf("access denied")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				// authentication always fails, handshake itself is probed
+				ssh.NewServerConn(conn, serverConfig)
+				conn.Close()
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+
+	cases := []struct {
+		Name        string
+		Fingerprint string
+		Status      string
+		Verified    bool
+	}{
+		{"match", fingerprint, "", true},
+		{"mismatch", "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8", schema.StatusCritical, false},
+		{"unknown", "", "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var config schema.GeneralConfig
+			config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+			config.Results = make(chan schema.ProbeResult, 1)
+			jobs := make(chan schema.Host, 1)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go SSH(1, config, jobs, &wg)
+
+			host := schema.Host{IP: "127.0.0.1", Port: port}
+			if tc.Fingerprint != "" {
+				host.Labels = map[string]string{"ssh_fingerprint": tc.Fingerprint}
+			}
+			jobs <- host
+			result := <-config.Results
+			close(jobs)
+			wg.Wait()
+
+			if result.Loss != 0 || result.SSH == nil {
+				t.Fatalf("expected successful handshake, got: %v", result.Output)
+			}
+			if result.Status != tc.Status || result.SSH.KeyVerified != tc.Verified {
+				t.Errorf("expected status %q verified %v, got: %q %v", tc.Status, tc.Verified, result.Status, result.SSH.KeyVerified)
+			}
+			if result.SSH.Fingerprint != fingerprint || !strings.HasPrefix(result.SSH.ServerVersion, "SSH-2.0-") {
+				t.Errorf("invalid handshake details, got: %v", result.SSH)
+			}
+		})
+	}
+}
+
+func TestSSHInvalidPort(t *testing.T) {
+	config := schema.GeneralConfig{Results: make(chan schema.ProbeResult, 2)}
+	jobs := make(chan schema.Host, 2)
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "0"}
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "22,80"}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	SSH(1, config, jobs, &wg)
+	close(config.Results)
+
+	var results int
+	for result := range config.Results {
+		results++
+		if result.Status != schema.StatusCritical || result.Loss != 100 {
+			t.Errorf("host with invalid port %s expected CRITICAL, got: %v", result.Host.Port, result.Status)
+		}
+	}
+	if results != 2 {
+		t.Errorf("expected result of each host, got: %d", results)
+	}
+}
+
+func TestSNMP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// responder returns sysUpTime decreasing between requests to simulate reboot
+	uptimes := []uint32{500000, 700000, 1200}
+	go func() {
+		buf := make([]byte, 1500)
+		for i := 0; ; i++ {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := (&gosnmp.GoSNMP{}).SnmpDecodePacket(buf[:n])
+			if err != nil {
+				continue
+			}
+			response := &gosnmp.SnmpPacket{
+				Version:   request.Version,
+				Community: request.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: request.RequestID,
+				Variables: []gosnmp.SnmpPDU{
+					{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uptimes[i%len(uptimes)]},
+					{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router1")},
+				},
+			}
+			out, err := response.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 1
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Probe.SNMP.Uptimes = snmpprobe.NewUptimes()
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go SNMP(1, config, jobs, &wg)
+
+	var results []schema.ProbeResult
+	for range uptimes {
+		jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+		results = append(results, <-config.Results)
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, result := range results {
+		if result.Loss != 0 || result.SNMP == nil || result.SNMP.SysName != "router1" {
+			t.Fatalf("expected response in round %d, got: %v", i+1, result.Output)
+		}
+	}
+	if results[1].Status != "" || results[1].SNMP.Uptime != 7000 {
+		t.Errorf("unexpected reboot in round 2, got: %v", results[1].Output)
+	}
+	if results[2].Status != schema.StatusRebooted || !results[2].SNMP.Rebooted {
+		t.Errorf("expected reboot in round 3, got: %v", results[2].Output)
+	}
+}
+
+func TestNTP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// responder's clock is 2s ahead
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			now := uint64(time.Now().Add(2*time.Second).Unix()+2208988800) << 32
+			response := make([]byte, 48)
+			response[0] = 4<<3 | 4
+			response[1] = 1
+			copy(response[12:], "GPS")
+			copy(response[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(response[32:], now)
+			binary.BigEndian.PutUint64(response[40:], now)
+			conn.WriteTo(response, addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 2
+	config.Probe.Interval.Duration = time.Duration(10) * time.Millisecond
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Probe.NTP.CriticalOffset.Duration = time.Duration(1) * time.Second
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go NTP(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+	result := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if result.Loss != 0 || result.NTP == nil {
+		t.Fatalf("expected NTP response, got: %v", result.Output)
+	}
+	// server timestamps are truncated to whole seconds
+	if result.NTP.Offset < 1 || result.NTP.Offset > 2.1 || result.NTP.Stratum != 1 || result.NTP.ReferenceID != "GPS" {
+		t.Errorf("invalid NTP result, got: %+v", result.NTP)
+	}
+	if result.Status != schema.StatusCritical {
+		t.Errorf("expected status %s, got: %s", schema.StatusCritical, result.Status)
+	}
+}
+
+// testDriver is database/sql driver returning one row for each query, connections to DSN with "down" fail.
+type testDriver struct{}
+type testConn struct{}
+type testRows struct{ done bool }
+
+func (testDriver) Open(dsn string) (driver.Conn, error) {
+	if strings.Contains(dsn, "down") {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return testConn{}, nil
+}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return nil, fmt.Errorf("not supported") }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("not supported") }
+func (testConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return &testRows{}, nil
+}
+
+func (r *testRows) Columns() []string { return []string{"?column?"} }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestSQL(t *testing.T) {
+	sql.Register("uping-test", testDriver{})
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 2
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Probe.SQL.Driver = "uping-test"
+	config.Probe.SQL.Template = template.Must(template.New("dsn").Parse(`{{.IP}}:{{.Port}}/{{index .Labels "db"}}`))
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go SQL(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "5432", Labels: map[string]string{"db": "inventory"}}
+	up := <-config.Results
+	jobs <- schema.Host{IP: "127.0.0.1", Port: "5432", Labels: map[string]string{"db": "down"}}
+	down := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if up.Loss != 0 || up.SQL == nil {
+		t.Errorf("expected successful query, got: %v", up.Output)
+	}
+	if down.Loss != 100 || down.SQL != nil || !strings.Contains(down.Output[0], "connection refused") {
+		t.Errorf("expected failed connection, got: %v", down.Output)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("billing", healthpb.HealthCheckResponse_NOT_SERVING)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	defer server.Stop()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 2
+	config.Probe.Interval.Duration = time.Duration(10) * time.Millisecond
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go GRPC(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+	serving := <-config.Results
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port, Labels: map[string]string{"grpc_service": "billing"}}
+	notServing := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if serving.Loss != 0 || serving.GRPC == nil || serving.GRPC.Status != "SERVING" || serving.Status != "" {
+		t.Errorf("expected SERVING server, got: %v", serving.Output)
+	}
+	if notServing.GRPC == nil || notServing.GRPC.Status != "NOT_SERVING" || notServing.Status != schema.StatusCritical {
+		t.Errorf("expected NOT_SERVING billing service, got: %v", notServing.Output)
+	}
+}
+
+func TestDHCP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// stand-in server offers 10.0.0.100 replying to sender, as to relay agent
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 240 || buf[0] != 1 || buf[242] != 1 {
+				continue
+			}
+			reply := make([]byte, 240)
+			copy(reply, buf[:240])
+			reply[0] = 2
+			copy(reply[16:20], net.IPv4(10, 0, 0, 100).To4())
+			reply = append(reply, 53, 1, 2, 54, 4, 10, 0, 0, 1, 51, 4, 0, 0, 0x0e, 0x10, 1, 4, 255, 255, 255, 0, 3, 4, 10, 0, 0, 1, 255)
+			conn.WriteTo(reply, addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	// find free local port offers are received on
+	local, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPort := local.LocalAddr().(*net.UDPAddr).Port
+	local.Close()
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 2
+	config.Probe.Interval.Duration = time.Duration(10) * time.Millisecond
+	config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+	config.Probe.DHCP.HardwareAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	config.Probe.DHCP.RelayAddr = net.IPv4(127, 0, 0, 1).To4()
+	config.Probe.DHCP.LocalPort = localPort
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go DHCP(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+	result := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if result.Loss != 0 || result.DHCP == nil {
+		t.Fatalf("expected DHCP offer, got: %v", result.Output)
+	}
+	expected := schema.DHCPResult{OfferedIP: "10.0.0.100", ServerID: "10.0.0.1", LeaseTime: 3600, SubnetMask: "255.255.255.0", Routers: []string{"10.0.0.1"}}
+	if !reflect.DeepEqual(*result.DHCP, expected) {
+		t.Errorf("invalid DHCP offer, got: %+v", *result.DHCP)
+	}
+}
+
+func TestRADIUS(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// stand-in server accepts user "test" with password "secret password", secret is "s3cret"
+	secret := []byte("s3cret")
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := buf[:n]
+
+			var user, password []byte
+			for attrs := request[20:]; len(attrs) >= 2 && int(attrs[1]) <= len(attrs); attrs = attrs[attrs[1]:] {
+				switch attrs[0] {
+				case 1:
+					user = attrs[2:attrs[1]]
+				case 2:
+					hidden := attrs[2:attrs[1]]
+					previous := request[4:20]
+					for i := 0; i < len(hidden); i += 16 {
+						hash := md5.Sum(append(append([]byte(nil), secret...), previous...))
+						for j := 0; j < 16; j++ {
+							password = append(password, hidden[i+j]^hash[j])
+						}
+						previous = hidden[i : i+16]
+					}
+				}
+			}
+
+			code := byte(3)
+			if string(user) == "test" && strings.TrimRight(string(password), "\x00") == "secret password" {
+				code = 2
+			}
+			response := []byte{code, request[1], 0, 20 + 7}
+			response = append(response, request[4:20]...)
+			response = append(response, 18, 7, 'h', 'e', 'l', 'l', 'o')
+			hash := md5.New()
+			hash.Write(response)
+			hash.Write(secret)
+			copy(response[4:20], hash.Sum(nil))
+			conn.WriteTo(response, addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	cases := []struct {
+		Name     string
+		Password string
+		Secret   string
+		Response string
+		Status   string
+	}{
+		{"accept", "secret password", "s3cret", "ACCEPT", ""},
+		{"reject", "wrong", "s3cret", "REJECT", schema.StatusCritical},
+		{"invalid authenticator", "secret password", "other", "", schema.StatusCritical},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var config schema.GeneralConfig
+			config.Probe.Count = 1
+			config.Probe.Timeout.Duration = time.Duration(1) * time.Second
+			config.Probe.RADIUS = schema.RADIUSConfig{User: "test", Password: tc.Password, Secret: tc.Secret}
+			config.Results = make(chan schema.ProbeResult, 1)
+			jobs := make(chan schema.Host, 1)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go RADIUS(1, config, jobs, &wg)
+
+			jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+			result := <-config.Results
+			close(jobs)
+			wg.Wait()
+
+			if result.Status != tc.Status {
+				t.Errorf("expected status %q, got: %q %v", tc.Status, result.Status, result.Output)
+			}
+			if tc.Response == "" {
+				if result.RADIUS != nil || result.Loss != 100 {
+					t.Errorf("expected invalid response, got: %v", result.Output)
+				}
+				return
+			}
+			if result.RADIUS == nil || result.RADIUS.Response != tc.Response || result.RADIUS.ReplyMessage != "hello" {
+				t.Errorf("expected response %s, got: %v", tc.Response, result.Output)
+			}
+		})
+	}
+}
+
+func TestTWAMP(t *testing.T) {
+	reflector, err := twampprobe.NewReflector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reflector.Close()
+	go reflector.Serve()
+	_, port, _ := net.SplitHostPort(reflector.Addr().String())
+
+	var config schema.GeneralConfig
+	config.Probe.Count = 3
+	config.Probe.Interval.Duration = 10 * time.Millisecond
+	config.Probe.Timeout.Duration = time.Second
+	config.Results = make(chan schema.ProbeResult, 1)
+	jobs := make(chan schema.Host, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go TWAMP(1, config, jobs, &wg)
+
+	jobs <- schema.Host{IP: "127.0.0.1", Port: port}
+	result := <-config.Results
+	close(jobs)
+	wg.Wait()
+
+	if result.Loss != 0 || result.TWAMP == nil {
+		t.Fatalf("expected reflected packets, got: %v", result.Output)
+	}
+	if result.TWAMP.ForwardLoss == nil || *result.TWAMP.ForwardLoss != 0 || *result.TWAMP.ReverseLoss != 0 {
+		t.Errorf("expected no loss in both directions, got: %v", result.Output)
+	}
+	if result.TWAMP.RoundTrip <= 0 || result.TWAMP.RoundTrip > 1 {
+		t.Errorf("invalid round-trip time, got: %v", result.TWAMP.RoundTrip)
+	}
+}